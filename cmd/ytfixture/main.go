@@ -0,0 +1,121 @@
+// Command ytfixture saves a YouTube player to youtube/testdata/players,
+// together with outputs of its signature and n transforms that are known
+// to be right, for the decipher tests. The outputs are given on the
+// command line, taken from a source that does not share our extraction,
+// such as yt-dlp's signature tests; nothing is computed here.
+//
+//	ytfixture -player URL -sig IN=OUT -n IN=OUT [DIR]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	youtube "example.com/ytdl/youtube"
+)
+
+// pairs collects repeated IN=OUT flags.
+type pairs map[string]string
+
+func (p pairs) String() string { return fmt.Sprint(map[string]string(p)) }
+
+func (p pairs) Set(s string) error {
+	in, out, ok := strings.Cut(s, "=")
+	if !ok || in == "" || out == "" {
+		return fmt.Errorf("want IN=OUT, got %q", s)
+	}
+	p[in] = out
+	return nil
+}
+
+// fixture is the JSON stored next to the player code.
+type fixture struct {
+	Player_url string            `json:"player_url"`
+	Signatures map[string]string `json:"signatures"`
+	N          map[string]string `json:"n"`
+}
+
+var playerNameRe = regexp.MustCompile(`/s/player/([0-9a-zA-Z_-]+)/([0-9a-zA-Z_.-]+)/`)
+
+func main() {
+	playerURL := flag.String("player", "", "Player URL, e.g. https://www.youtube.com/s/player/1f8742dc/player_ias.vflset/en_US/base.js")
+	name := flag.String("name", "", "Fixture name (default: the player hash and variant)")
+	f := fixture{Signatures: pairs{}, N: pairs{}}
+	flag.Var(pairs(f.Signatures), "sig", "Signature input and its deciphered output, as IN=OUT (repeatable)")
+	flag.Var(pairs(f.N), "n", "n input and its transformed output, as IN=OUT (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: ytfixture -player URL -sig IN=OUT -n IN=OUT [DIR]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *playerURL == "" || len(f.Signatures)+len(f.N) == 0 || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := filepath.Join("youtube", "testdata", "players")
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if *name == "" {
+		m := playerNameRe.FindStringSubmatch(*playerURL)
+		if m == nil {
+			fmt.Println("Can't name the fixture after", *playerURL, "- use -name")
+			os.Exit(2)
+		}
+		*name = m[1] + "-" + m[2]
+	}
+	f.Player_url = *playerURL
+
+	code, err := fetch(*playerURL)
+	if err != nil {
+		fmt.Println("Error fetching player:", err)
+		os.Exit(1)
+	}
+	meta, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	base := filepath.Join(dir, *name)
+	if err := os.WriteFile(base+".js", code, 0644); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(base+".json", append(meta, '\n'), 0644); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved %s.js and %s.json\n", base, base)
+}
+
+func fetch(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", youtube.ClientWeb.User_agent)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	youtube "example.com/ytdl/youtube"
+)
+
+// runDecipherCheck implements the "decipher-check" subcommand, which
+// reports how extraction and deciphering go for one player.
+func runDecipherCheck(args []string) {
+	fs := flag.NewFlagSet("decipher-check", flag.ExitOnError)
+	engineName := fs.String("engine", "goja", "Engine to run the samples with: goja, node, deno, qjs, static")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ytdownload decipher-check [flags] PLAYER_URL|BASE_JS_FILE")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	engine, ok := youtube.ParseEngine(*engineName)
+	if !ok {
+		fmt.Println("Unknown engine:", *engineName)
+		os.Exit(2)
+	}
+
+	r, err := youtube.CheckPlayer(context.Background(), fs.Arg(0), engine)
+	if err != nil {
+		fmt.Println("Error loading player:", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(r)
+	} else {
+		printPlayerReport(r)
+	}
+
+	if r.Sig_err != "" || r.N_err != "" || r.Setup_err != "" {
+		os.Exit(1)
+	}
+	// Samples the engine does not support are not failures
+	for _, s := range r.Samples {
+		if s.Err != "" {
+			os.Exit(1)
+		}
+	}
+}
+
+func printPlayerReport(r *youtube.PlayerReport) {
+	fmt.Printf("Player\t\t: %s (%d bytes)\n", r.Source, r.Size)
+	if r.Parse_err != "" {
+		fmt.Printf("Parse\t\t: FAILED in %s: %s\n", ms(r.Parse_time), r.Parse_err)
+	} else {
+		fmt.Printf("Parse\t\t: ok in %s\n", ms(r.Parse_time))
+	}
+
+	if r.Regex_pattern == 0 {
+		fmt.Println("Regex\t\t: no pattern matched")
+	} else {
+		helper := "none"
+		if r.Helper_name != "" {
+			helper = r.Helper_name + " (not found)"
+			if r.Helper_found {
+				helper = r.Helper_name + " (found)"
+			}
+		}
+		fmt.Printf("Regex\t\t: pattern %d matched %s, helper %s\n", r.Regex_pattern, r.Regex_name, helper)
+	}
+
+	fmt.Printf("Extraction\t: %s\n", ms(r.Extract_time))
+	if r.Sts == 0 {
+		fmt.Println("Timestamp\t: not found")
+	} else {
+		fmt.Printf("Timestamp\t: %d\n", r.Sts)
+	}
+	if r.Sig_err != "" {
+		fmt.Printf("Signature\t: FAILED: %s\n", r.Sig_err)
+	} else {
+		fmt.Printf("Signature\t: %s\n", r.Sig_name)
+	}
+	if len(r.Sig_ops) > 0 {
+		var ops []string
+		for _, op := range r.Sig_ops {
+			if op.Kind == youtube.OpReverse {
+				ops = append(ops, op.Kind)
+			} else {
+				ops = append(ops, fmt.Sprintf("%s %d", op.Kind, op.Arg))
+			}
+		}
+		fmt.Printf("Operations\t: %s\n", strings.Join(ops, ", "))
+	}
+	if r.N_err != "" {
+		fmt.Printf("N function\t: FAILED: %s\n", r.N_err)
+	} else {
+		fmt.Printf("N function\t: %s\n", r.N_name)
+	}
+
+	if r.Setup_err != "" {
+		fmt.Printf("Engine\t\t: %s FAILED: %s\n", r.Engine, r.Setup_err)
+		return
+	}
+	fmt.Printf("Engine\t\t: %s (setup %s)\n", r.Engine, ms(r.Setup_time))
+	for _, s := range r.Samples {
+		result := s.Output
+		switch {
+		case s.Skipped != "":
+			result = "skipped: " + s.Skipped
+		case s.Err != "":
+			result = "FAILED: " + s.Err
+		}
+		fmt.Printf("\t%-9s\t%s\t%s\n\t\t\t→ %s\n", s.Kind, ms(s.Duration), s.Input, result)
+	}
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
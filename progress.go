@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	youtube "example.com/ytdl/youtube"
+)
+
+// logProgressInterval is how often progress is printed when the output
+// is not a terminal.
+const logProgressInterval = 5 * time.Second
+
+// progressPrinter renders download progress. On a terminal one status
+// line is redrawn in place; otherwise, as when the output goes to a log,
+// a plain line is printed every logProgressInterval.
+type progressPrinter struct {
+	out     *os.File
+	tty     bool
+	drawn   bool      // a status line is on screen without its newline
+	printed time.Time // when the last plain line was printed
+}
+
+func newProgressPrinter(out *os.File) *progressPrinter {
+	return &progressPrinter{out: out, tty: isTerminal(out)}
+}
+
+func isTerminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// handle is a youtube.ProgressFunc.
+func (p *progressPrinter) handle(e youtube.ProgressEvent) {
+	switch e.Kind {
+	case youtube.ProgressStarted:
+		if e.Downloaded > 0 {
+			p.println("Resuming at " + e.String())
+		}
+		p.printed = time.Now()
+
+	case youtube.ProgressBytes:
+		line := fmt.Sprintf("%s\t%s", e.Elapsed.Truncate(time.Second), e)
+		if p.tty {
+			// Return to the start of the line and clear it
+			fmt.Fprint(p.out, "\r\033[K"+line)
+			p.drawn = true
+		} else if time.Since(p.printed) >= logProgressInterval {
+			p.println(line)
+			p.printed = time.Now()
+		}
+
+	case youtube.ProgressRetry:
+		if e.Wait > 0 {
+			p.println(fmt.Sprintf("Retrying in %s (attempt %d): %v", e.Wait.Round(100*time.Millisecond), e.Attempt, e.Err))
+		} else {
+			p.println(fmt.Sprintf("Retrying (attempt %d): %v", e.Attempt, e.Err))
+		}
+
+	case youtube.ProgressRestarted:
+		p.println(fmt.Sprintf("Restarting from the first byte: %v", e.Err))
+
+	case youtube.ProgressFinished:
+		p.println(fmt.Sprintf("Download took %s\t%s", e.Elapsed.Round(time.Millisecond), e))
+
+	case youtube.ProgressFailed:
+		// The caller reports the error
+		p.endLine()
+	}
+}
+
+func (p *progressPrinter) println(s string) {
+	p.endLine()
+	fmt.Fprintln(p.out, s)
+}
+
+// endLine moves past a status line left on screen.
+func (p *progressPrinter) endLine() {
+	if p.drawn {
+		fmt.Fprintln(p.out)
+		p.drawn = false
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	youtube "example.com/ytdl/youtube"
+)
+
+var (
+	searchTypes = map[string]youtube.SearchType{
+		"any": youtube.AnyType, "video": youtube.TypeVideo, "channel": youtube.TypeChannel,
+		"playlist": youtube.TypePlaylist, "movie": youtube.TypeMovie,
+	}
+	searchSorts = map[string]youtube.SearchSort{
+		"relevance": youtube.SortRelevance, "rating": youtube.SortRating,
+		"date": youtube.SortUploadDate, "views": youtube.SortViewCount,
+	}
+	searchDates = map[string]youtube.UploadDate{
+		"any": youtube.AnyDate, "hour": youtube.LastHour, "today": youtube.Today,
+		"week": youtube.ThisWeek, "month": youtube.ThisMonth, "year": youtube.ThisYear,
+	}
+	searchDurations = map[string]youtube.SearchDuration{
+		"any": youtube.AnyDuration, "short": youtube.DurationShort,
+		"medium": youtube.DurationMedium, "long": youtube.DurationLong,
+	}
+)
+
+// runSearch implements the "search" subcommand.
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	kind := fs.String("type", "any", "Result type: any, video, channel, playlist, movie")
+	sort := fs.String("sort", "relevance", "Sort order: relevance, rating, date, views")
+	date := fs.String("date", "any", "Upload date: any, hour, today, week, month, year")
+	duration := fs.String("duration", "any", "Duration: any, short (<4m), medium (4-20m), long (>20m)")
+	features := fs.String("features", "", "Comma-separated features: hd, subtitles, creative-commons, 3d, live, purchased, 4k, 360, location, hdr, vr180")
+	pages := fs.Int("pages", 1, "Number of result pages to fetch")
+	asJSON := fs.Bool("json", false, "Print results as JSON")
+	pick := fs.Bool("pick", false, "Pick a video result to show and download")
+	itag := fs.Int("itag", 0, "Select format by itag when downloading the picked video")
+	useYtDlp := fs.Bool("use-ytdlp", true, "Use yt-dlp for downloads (recommended)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ytdownload search [flags] QUERY")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	query := strings.Join(fs.Args(), " ")
+	if query == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	filters := &youtube.SearchFilters{}
+	var ok bool
+	if filters.Type, ok = searchTypes[*kind]; !ok {
+		fmt.Println("Unknown type:", *kind)
+		os.Exit(2)
+	}
+	if filters.Sort_by, ok = searchSorts[*sort]; !ok {
+		fmt.Println("Unknown sort order:", *sort)
+		os.Exit(2)
+	}
+	if filters.Upload_date, ok = searchDates[*date]; !ok {
+		fmt.Println("Unknown upload date:", *date)
+		os.Exit(2)
+	}
+	if filters.Duration, ok = searchDurations[*duration]; !ok {
+		fmt.Println("Unknown duration:", *duration)
+		os.Exit(2)
+	}
+	if *features != "" {
+		for _, name := range strings.Split(*features, ",") {
+			feature, ok := youtube.ParseSearchFeature(strings.ToLower(strings.TrimSpace(name)))
+			if !ok {
+				fmt.Println("Unknown feature:", name)
+				os.Exit(2)
+			}
+			filters.Features = append(filters.Features, feature)
+		}
+	}
+
+	ctx := signalContext()
+	page, err := youtube.SearchContext(ctx, query, filters)
+	if err != nil {
+		fmt.Println("Error searching:", err)
+		os.Exit(exitCode(err))
+	}
+	results := page.Results
+	for i := 1; i < *pages && page.HasNext(); i++ {
+		page, err = page.NextContext(ctx)
+		if err != nil {
+			fmt.Println("Error fetching more results:", err)
+			if ctx.Err() != nil {
+				os.Exit(exitCode(err))
+			}
+			break
+		}
+		results = append(results, page.Results...)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	} else {
+		printSearchResults(results)
+	}
+
+	if !*pick || len(results) == 0 {
+		return
+	}
+
+	fmt.Printf("Pick a result [0-%d]: ", len(results)-1)
+	var i int
+	if _, err := fmt.Scanf("%d", &i); err != nil || i < 0 || i >= len(results) {
+		fmt.Println("Invalid entry:", i)
+		os.Exit(1)
+	}
+	result := results[i]
+	if result.Kind != youtube.KindVideo {
+		fmt.Printf("Only videos can be downloaded from search results; for this %s run: ytdownload -id=%s\n", result.Kind, result.Id)
+		return
+	}
+
+	video, err := youtube.GetContext(ctx, result.Id)
+	if err != nil {
+		fmt.Println("Error fetching metadata:", err)
+		os.Exit(exitCode(err))
+	}
+	printVideoMeta(video)
+
+	var index int
+	if *itag > 0 {
+		idx, format := video.IndexByItag(*itag)
+		if format == nil {
+			fmt.Println("Unknown itag:", *itag)
+			os.Exit(1)
+		}
+		index = idx
+	} else {
+		index = getItag(len(video.Formats) - 1)
+	}
+	if err := checkFormat(ctx, video, index); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitCode(err))
+	}
+
+	if err := downloadVideo(ctx, video, index, &youtube.Option{Progress: newProgressPrinter(os.Stdout).handle}, *useYtDlp); err != nil {
+		os.Exit(exitCode(err))
+	}
+}
+
+func printSearchResults(results []youtube.SearchResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tTYPE\tID\tTITLE\tAUTHOR\tLENGTH\tINFO")
+	for i, r := range results {
+		length := ""
+		if r.Length_seconds > 0 {
+			length = (time.Duration(r.Length_seconds) * time.Second).String()
+		}
+		info := r.Views
+		switch r.Kind {
+		case youtube.KindChannel:
+			info = r.Subscribers
+		case youtube.KindPlaylist:
+			info = r.Video_count + " videos"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", i, r.Kind, r.Id, truncate(r.Title, 60), truncate(r.Author, 25), length, info)
+	}
+	w.Flush()
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
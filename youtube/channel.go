@@ -0,0 +1,253 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	URL_CHANNEL = "https://www.youtube.com/channel/"
+)
+
+// ChannelTab selects one of the video listings of a channel.
+type ChannelTab string
+
+const (
+	TabVideos ChannelTab = "videos"
+	TabShorts ChannelTab = "shorts"
+	TabLive   ChannelTab = "streams"
+)
+
+var ChannelTabs = []ChannelTab{TabVideos, TabShorts, TabLive}
+
+// ParseChannelTab maps a tab name such as "shorts" to its ChannelTab.
+func ParseChannelTab(name string) (ChannelTab, bool) {
+	for _, t := range ChannelTabs {
+		if string(t) == strings.ToLower(strings.TrimSpace(name)) {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+var channelIdRe = regexp.MustCompile(`^UC[A-Za-z0-9_-]{22}$`)
+
+type Channel struct {
+	Id, Name, Handle, Description string
+	Subscribers                   string // as displayed, e.g. "1.2M subscribers"
+	Avatar_url, Banner_url        string
+}
+
+// IsChannelURL reports whether input looks like a channel reference that
+// GetChannel can resolve.
+func IsChannelURL(input string) bool {
+	_, err := channelPath(input)
+	return err == nil
+}
+
+// GetChannel resolves a /@handle, /channel/UC…, /c/name or /user/name URL,
+// a bare @handle or a channel ID, and fetches the channel's metadata.
+func GetChannel(idOrURL string) (*Channel, error) {
+	return GetChannelContext(context.Background(), idOrURL)
+}
+
+// GetChannelContext is like GetChannel but gives up when ctx is done.
+func GetChannelContext(ctx context.Context, idOrURL string) (*Channel, error) {
+	path, err := channelPath(idOrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	id := strings.TrimPrefix(path, "/channel/")
+	if !channelIdRe.MatchString(id) {
+		id, err = resolveChannelId(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page, err := fetchPage(ctx, URL_CHANNEL+id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := extractInitialData(page)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := dig(data, "metadata", "channelMetadataRenderer")
+	if meta == nil {
+		return nil, fmt.Errorf("could not find channel metadata for %s", id)
+	}
+
+	channel := &Channel{
+		Id:          id,
+		Name:        digString(meta, "title"),
+		Description: digString(meta, "description"),
+		Avatar_url:  lastThumbnail(dig(meta, "avatar", "thumbnails")),
+	}
+	if vanity := digString(meta, "vanityChannelUrl"); vanity != "" {
+		if i := strings.LastIndex(vanity, "/@"); i >= 0 {
+			channel.Handle = vanity[i+1:]
+		}
+	}
+
+	// Older channels still render the c4 header, newer ones a page header
+	// view model.
+	if header := dig(data, "header", "c4TabbedHeaderRenderer"); header != nil {
+		channel.Subscribers = textOf(dig(header, "subscriberCountText"))
+		channel.Banner_url = lastThumbnail(dig(header, "banner", "thumbnails"))
+	} else {
+		header := dig(data, "header")
+		walk(header, "imageBannerViewModel", func(r map[string]interface{}) {
+			if channel.Banner_url == "" {
+				channel.Banner_url = lastThumbnail(dig(r, "image", "sources"))
+			}
+		})
+		channel.Subscribers = findString(header, "subscriber")
+	}
+
+	return channel, nil
+}
+
+// Entries lists every video on one of the channel's tabs, following
+// continuations to the end.
+func (c *Channel) Entries(tab ChannelTab) ([]Entry, error) {
+	return c.EntriesContext(context.Background(), tab)
+}
+
+// EntriesContext is like Entries but gives up when ctx is done.
+func (c *Channel) EntriesContext(ctx context.Context, tab ChannelTab) ([]Entry, error) {
+	page, err := fetchPage(ctx, URL_CHANNEL+c.Id+"/"+string(tab))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := extractInitialData(page)
+	if err != nil {
+		return nil, err
+	}
+
+	// A missing tab redirects to the channel home page.
+	if !tabSelected(data, tab) {
+		return nil, fmt.Errorf("channel %s has no %s tab", c.Id, tab)
+	}
+
+	entries, err := browseEntries(ctx, data, func(node interface{}, offset int) []Entry {
+		return channelEntries(node, c, offset)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch channel continuation: %w", err)
+	}
+	return entries, nil
+}
+
+func (c *Channel) Videos() ([]Entry, error) { return c.Entries(TabVideos) }
+func (c *Channel) Shorts() ([]Entry, error) { return c.Entries(TabShorts) }
+func (c *Channel) Live() ([]Entry, error)   { return c.Entries(TabLive) }
+
+// channelEntries collects the video, reel and shorts lockup renderers
+// below node. offset is the number of entries on earlier pages.
+func channelEntries(node interface{}, c *Channel, offset int) []Entry {
+	var entries []Entry
+	keys := []string{"videoRenderer", "reelItemRenderer", "shortsLockupViewModel"}
+	walkAll(node, keys, func(key string, r map[string]interface{}) {
+		entry := Entry{
+			Index:        offset + len(entries) + 1,
+			Author:       c.Name,
+			Availability: AvailabilityPublic,
+		}
+		switch key {
+		case "videoRenderer":
+			entry.Id = digString(r, "videoId")
+			entry.Title = textOf(r["title"])
+			entry.Length_seconds = parseClock(textOf(r["lengthText"]))
+		case "reelItemRenderer":
+			entry.Id = digString(r, "videoId")
+			entry.Title = textOf(r["headline"])
+		case "shortsLockupViewModel":
+			entry.Id = digString(r, "onTap", "innertubeCommand", "reelWatchEndpoint", "videoId")
+			entry.Title = digString(r, "overlayMetadata", "primaryText", "content")
+		}
+		if entry.Id != "" {
+			entries = append(entries, entry)
+		}
+	})
+	return entries
+}
+
+// tabSelected reports whether the page's selected tab is the one asked
+// for.
+func tabSelected(data map[string]interface{}, tab ChannelTab) bool {
+	selected := true
+	walk(dig(data, "contents"), "tabRenderer", func(r map[string]interface{}) {
+		if r["selected"] != true {
+			return
+		}
+		tabURL := digString(r, "endpoint", "commandMetadata", "webCommandMetadata", "url")
+		if tabURL != "" && !strings.HasSuffix(tabURL, "/"+string(tab)) {
+			selected = false
+		}
+	})
+	return selected
+}
+
+// channelPath normalises a channel reference to the URL path YouTube
+// serves it under, e.g. "/@handle" or "/channel/UC…".
+func channelPath(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	switch {
+	case channelIdRe.MatchString(input):
+		return "/channel/" + input, nil
+	case strings.HasPrefix(input, "@"):
+		return "/" + input, nil
+	}
+
+	raw := input
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", &URLError{Input: input, Reason: err.Error()}
+	}
+	if !youtubeHosts[strings.ToLower(u.Hostname())] {
+		return "", &URLError{Input: input, Reason: "not a YouTube host"}
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(parts) >= 1 && strings.HasPrefix(parts[0], "@"):
+		return "/" + parts[0], nil
+	case len(parts) >= 2 && parts[0] == "channel" && channelIdRe.MatchString(parts[1]):
+		return "/channel/" + parts[1], nil
+	case len(parts) >= 2 && (parts[0] == "c" || parts[0] == "user"):
+		return "/" + parts[0] + "/" + parts[1], nil
+	}
+	return "", &URLError{Input: input, Reason: "not a channel URL"}
+}
+
+// resolveChannelId maps a handle, custom or legacy user path to the
+// channel ID through the navigation/resolve_url endpoint.
+func resolveChannelId(ctx context.Context, path string) (string, error) {
+	body := map[string]interface{}{
+		"context": ClientWeb.context(),
+		"url":     "https://www.youtube.com" + path,
+	}
+
+	var data map[string]interface{}
+	if err := innertubeRequest(ctx, "navigation/resolve_url", ClientWeb, body, &data); err != nil {
+		return "", err
+	}
+
+	id := digString(data, "endpoint", "browseEndpoint", "browseId")
+	if !channelIdRe.MatchString(id) {
+		return "", errors.New("could not resolve channel " + path)
+	}
+	return id, nil
+}
@@ -0,0 +1,184 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultChapterTemplate names chapter files "<id> - 01 - <title>.<ext>".
+const DefaultChapterTemplate = "{id} - {index} - {title}.{ext}"
+
+type Chapter struct {
+	Title      string
+	Start, End time.Duration
+}
+
+var (
+	descriptionChapterRe = regexp.MustCompile(`^[\s\-*•]*[(\[]?((?:\d{1,2}:)?\d{1,2}:\d{2})[)\]]?\s*[-–—:|]?\s*(.+?)\s*$`)
+	unsafeFilenameRe     = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]+`)
+)
+
+// chaptersFromData reads chapters from the macro markers of a watch
+// page's ytInitialData.
+func chaptersFromData(data map[string]interface{}, length time.Duration) []Chapter {
+	if data == nil {
+		return nil
+	}
+
+	var chapters []Chapter
+	walk(data, "chapterRenderer", func(r map[string]interface{}) {
+		ms, err := strconv.ParseInt(digString(r, "timeRangeStartMillis"), 10, 64)
+		if err != nil {
+			return
+		}
+		chapters = append(chapters, Chapter{
+			Title: textOf(r["title"]),
+			Start: time.Duration(ms) * time.Millisecond,
+		})
+	})
+
+	if len(chapters) == 0 {
+		walk(data, "macroMarkersListItemRenderer", func(r map[string]interface{}) {
+			start, err := strconv.Atoi(digString(r, "onTap", "watchEndpoint", "startTimeSeconds"))
+			if err != nil {
+				start = parseClock(textOf(r["timeDescription"]))
+			}
+			chapters = append(chapters, Chapter{
+				Title: textOf(r["title"]),
+				Start: time.Duration(start) * time.Second,
+			})
+		})
+	}
+
+	return finishChapters(chapters, length)
+}
+
+// chaptersFromDescription reads "0:00 Intro" style timestamps from a video
+// description. Like YouTube, it requires the list to start at 0:00 and to
+// have at least three ascending entries.
+func chaptersFromDescription(description string, length time.Duration) []Chapter {
+	var chapters []Chapter
+	for _, line := range strings.Split(description, "\n") {
+		m := descriptionChapterRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		start := time.Duration(parseClock(m[1])) * time.Second
+		if len(chapters) == 0 && start != 0 {
+			continue
+		}
+		if len(chapters) > 0 && start <= chapters[len(chapters)-1].Start {
+			continue
+		}
+		chapters = append(chapters, Chapter{Title: m[2], Start: start})
+	}
+
+	if len(chapters) < 3 {
+		return nil
+	}
+	return finishChapters(chapters, length)
+}
+
+// finishChapters fills in each chapter's End from the next chapter's
+// Start, the last one ending with the video.
+func finishChapters(chapters []Chapter, length time.Duration) []Chapter {
+	// Macro markers are listed more than once on some pages.
+	var out []Chapter
+	for _, c := range chapters {
+		if len(out) > 0 && c.Start <= out[len(out)-1].Start {
+			continue
+		}
+		out = append(out, c)
+	}
+
+	for i := range out {
+		if i+1 < len(out) {
+			out[i].End = out[i+1].Start
+		} else {
+			out[i].End = length
+		}
+	}
+	return out
+}
+
+// checkFfmpegInstalled checks if ffmpeg is available in PATH
+func checkFfmpegInstalled() error {
+	_, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return errors.New("ffmpeg not found. Install it with: brew install ffmpeg")
+	}
+	return nil
+}
+
+// SplitChapters cuts a downloaded file into one file per chapter, named
+// from template. The template may use {id}, {index}, {title} and {ext};
+// an empty template means DefaultChapterTemplate. Streams are copied, not
+// re-encoded, so cuts land on the nearest keyframe. The files are written
+// next to filename and their paths returned.
+func (v *Video) SplitChapters(filename, template string) ([]string, error) {
+	return v.SplitChaptersContext(context.Background(), filename, template)
+}
+
+// SplitChaptersContext is like SplitChapters but stops ffmpeg and returns
+// when ctx is done. The files finished so far are returned.
+func (v *Video) SplitChaptersContext(ctx context.Context, filename, template string) ([]string, error) {
+	if len(v.Chapters) == 0 {
+		return nil, errors.New("video has no chapters")
+	}
+	if err := checkFfmpegInstalled(); err != nil {
+		return nil, err
+	}
+	if template == "" {
+		template = DefaultChapterTemplate
+	}
+
+	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
+	dir := filepath.Dir(filename)
+
+	var files []string
+	for i, c := range v.Chapters {
+		name := strings.NewReplacer(
+			"{id}", v.Id,
+			"{index}", fmt.Sprintf("%02d", i+1),
+			"{title}", sanitizeFilename(c.Title),
+			"{ext}", ext,
+		).Replace(template)
+		out := filepath.Join(dir, name)
+
+		args := []string{"-y", "-loglevel", "error", "-ss", ffmpegTime(c.Start)}
+		if c.End > c.Start {
+			args = append(args, "-t", ffmpegTime(c.End-c.Start))
+		}
+		args = append(args, "-i", filename, "-map", "0", "-c", "copy", out)
+
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return files, ctx.Err()
+			}
+			return files, fmt.Errorf("ffmpeg failed on chapter %q: %v: %s", c.Title, err, strings.TrimSpace(stderr.String()))
+		}
+		files = append(files, out)
+	}
+
+	return files, nil
+}
+
+func ffmpegTime(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+func sanitizeFilename(s string) string {
+	s = unsafeFilenameRe.ReplaceAllString(s, "_")
+	return strings.Trim(s, " .")
+}
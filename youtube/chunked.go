@@ -0,0 +1,240 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultChunkSize is the size of each range request when
+// Option.Chunk_size is not set.
+const DefaultChunkSize = 10 << 20
+
+// errRangeIgnored is returned for a chunk when the server answers its
+// range request with the whole stream.
+var errRangeIgnored = errors.New("server ignored the range")
+
+// byteRange is the half-open range [start, end) of a stream.
+type byteRange struct {
+	start, end int64
+}
+
+// downloadChunks fetches the stream as consecutive ranges of
+// state.Chunk_size bytes, workers at a time, and writes each at its
+// offset in the file. Chunks that the state lists as done are skipped,
+// and each one completed is recorded there. A chunk that fails is retried
+// on its own, from its first missing byte.
+func (t *transfer) downloadChunks(ctx context.Context, workers int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	state := t.state
+	done := make(map[int]bool, len(state.Done))
+	for _, i := range state.Done {
+		done[i] = true
+	}
+
+	type chunk struct {
+		i int
+		byteRange
+	}
+	// The producer may outlive the workers, so it works on copies
+	size, length := state.Chunk_size, state.Length
+	chunks := make(chan chunk)
+	go func() {
+		defer close(chunks)
+		for i := 0; int64(i)*size < length; i++ {
+			if done[i] {
+				continue
+			}
+			start := int64(i) * size
+			select {
+			case chunks <- chunk{i, byteRange{start, min(start+size, length)}}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var first error
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				err := t.fetchChunk(ctx, c.byteRange)
+				if err == nil {
+					err = state.chunkDone(c.i)
+				}
+				if err != nil {
+					once.Do(func() {
+						first = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if first != nil {
+		return first
+	}
+	return ctx.Err()
+}
+
+// url resolves the stream URL for a chunk request.
+func (t *transfer) url(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.video.streamURL(ctx, t.index)
+}
+
+// stale refreshes the metadata after streamURL was refused, unless
+// another worker has done so already.
+func (t *transfer) stale(ctx context.Context, streamURL string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.video.Formats[t.index].Url != streamURL {
+		return nil
+	}
+	return t.video.refresh(ctx)
+}
+
+// fetchChunk downloads c, retrying from where the previous attempt
+// stopped.
+func (t *transfer) fetchChunk(ctx context.Context, c byteRange) error {
+	err := t.retry.do(ctx, t.progress.retry, func() error {
+		n, err := t.fetchRange(ctx, c)
+		c.start += n
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download bytes %d-%d: %w", c.start, c.end-1, err)
+	}
+	return nil
+}
+
+// fetchRange requests c and writes what arrives at its offset in the
+// file. It returns the number of bytes written, which is less than the
+// range on error.
+func (t *transfer) fetchRange(ctx context.Context, c byteRange) (int64, error) {
+	resp, err := t.openRange(ctx, c)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return 0, errRangeIgnored
+	case http.StatusForbidden:
+		return 0, errors.New("video forbidden")
+	default:
+		return 0, newStatusError(resp)
+	}
+	if start, _, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || start != c.start {
+		return 0, fmt.Errorf("server sent the wrong range: %q", resp.Header.Get("Content-Range"))
+	}
+
+	w := &countingWriter{w: io.NewOffsetWriter(t.out, c.start), n: &t.written}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, c.end-c.start))
+	if err == nil && n < c.end-c.start {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// openRange requests c. Like openStream, it refreshes the metadata and
+// tries once more when the URL is refused.
+func (t *transfer) openRange(ctx context.Context, c byteRange) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		streamURL, err := t.url(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req, err := newStreamRequest(ctx, "GET", streamURL)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", c.start, c.end-1))
+
+		resp, err := t.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusForbidden || attempt > 0 {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+		if err := t.stale(ctx, streamURL); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// unchunk drops what the chunks wrote and records the download as a
+// single stream from the first byte.
+func (t *transfer) unchunk() error {
+	t.state.Chunk_size, t.state.Done = 0, nil
+	if err := t.state.save(); err != nil {
+		return err
+	}
+	return t.restart(errRangeIgnored)
+}
+
+// parseContentRange parses a Content-Range header such as
+// "bytes 0-99/1000". total is -1 when the server sent "*".
+func parseContentRange(h string) (start, end, total int64, ok bool) {
+	spec, found := strings.CutPrefix(h, "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+	first, last, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	var err error
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, 0, false
+	}
+	if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+		return 0, 0, 0, false
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, 0, false
+		}
+	}
+	return start, end, total, true
+}
+
+// countingWriter adds the bytes written through it to n, for progress
+// reporting.
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,396 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// DecipherLimits bound every call into player code, which is untrusted
+// and changes weekly.
+type DecipherLimits struct {
+	Timeout   time.Duration // per call, unless the context ends sooner
+	Max_stack int           // JavaScript call depth
+	Max_alloc int64         // heap growth during a goja call, or the heap limit of an external runtime
+}
+
+var DefaultDecipherLimits = DecipherLimits{
+	Timeout:   2 * time.Second,
+	Max_stack: 1000,
+	Max_alloc: 256 << 20,
+}
+
+var (
+	ErrDecipherTimeout = errors.New("decipher timed out")
+	ErrDecipherLimit   = errors.New("decipher exceeded resource limits")
+)
+
+// maxTimeouts is how many calls in a row may run out of their time budget
+// before the transform is given up on.
+const maxTimeouts = 3
+
+// Decipherer runs the signature and n transforms of one player.
+// Implementations are safe to use from multiple goroutines.
+type Decipherer interface {
+	// Signature deciphers the s parameter of a signatureCipher.
+	Signature(ctx context.Context, s string) (string, error)
+	// N transforms the n throttling parameter of a stream URL.
+	N(ctx context.Context, n string) (string, error)
+}
+
+// Engine selects the Decipherer implementation.
+type Engine string
+
+const (
+	EngineGoja    Engine = "goja"   // built-in interpreter
+	EngineNode    Engine = "node"   // see ExternalDecipherer
+	EngineDeno    Engine = "deno"   // see ExternalDecipherer
+	EngineQuickJS Engine = "qjs"    // see ExternalDecipherer
+	EngineStatic  Engine = "static" // see StaticDecipherer
+)
+
+var Engines = []Engine{EngineGoja, EngineNode, EngineDeno, EngineQuickJS, EngineStatic}
+
+// ParseEngine maps an engine name such as "node" to its Engine.
+func ParseEngine(name string) (Engine, bool) {
+	for _, e := range Engines {
+		if string(e) == strings.ToLower(strings.TrimSpace(name)) {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+// Decipherers are kept for the lifetime of the process, one per engine
+// and player.
+var decipherers sync.Map // engine + " " + player URL -> Decipherer
+
+// decipherFor returns the Decipherer for p, building it on first use. An
+// engine that cannot be set up yields a Decipherer that reports why.
+func decipherFor(p *player, engine Engine) Decipherer {
+	if engine == "" {
+		engine = EngineGoja
+	}
+	key := string(engine) + " " + p.URL
+	if d, ok := decipherers.Load(key); ok {
+		return d.(Decipherer)
+	}
+
+	d, err := newDecipherer(p, engine)
+	if err != nil {
+		d = failedDecipherer{err}
+	}
+	actual, _ := decipherers.LoadOrStore(key, d)
+	return actual.(Decipherer)
+}
+
+func newDecipherer(p *player, engine Engine) (Decipherer, error) {
+	switch engine {
+	case EngineGoja, "":
+		return newGojaDecipherer(p), nil
+	case EngineNode, EngineDeno, EngineQuickJS:
+		return newExternalDecipherer(p, engine)
+	case EngineStatic:
+		return newStaticDecipherer(p)
+	}
+	return nil, fmt.Errorf("unknown decipher engine %q", engine)
+}
+
+type failedDecipherer struct{ err error }
+
+func (d failedDecipherer) Signature(context.Context, string) (string, error) { return "", d.err }
+func (d failedDecipherer) N(context.Context, string) (string, error)         { return "", d.err }
+
+// ctxError reports why ctx ended. A deadline, whether of the caller or of
+// the time budget, is reported as ErrDecipherTimeout.
+func ctxError(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrDecipherTimeout, err)
+	}
+	return err
+}
+
+// nMemo remembers recent n transforms. Every format of a video shares its
+// n value, so a small memo saves nearly every call; it is emptied when
+// full to stay bounded.
+type nMemo struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+const nMemoSize = 256
+
+func (m *nMemo) load(n string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out, ok := m.m[n]
+	return out, ok
+}
+
+func (m *nMemo) store(n, out string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.m == nil || len(m.m) >= nMemoSize {
+		m.m = make(map[string]string)
+	}
+	m.m[n] = out
+}
+
+// checkN rejects n transform results that signal a failure inside the
+// player code.
+func checkN(n, out string) error {
+	if out == n || strings.HasPrefix(out, "enhanced_except_") {
+		return fmt.Errorf("n transform failed: %s", out)
+	}
+	return nil
+}
+
+// GojaDecipherer runs the transforms in the built-in goja interpreter.
+// The extracted code is compiled once into programs, and VMs that have
+// run them are pooled, so it is cheap to call for every format.
+type GojaDecipherer struct {
+	sigName, nName string
+	sigErr, nErr   error
+	programs       []*goja.Program
+	limits         DecipherLimits
+
+	vms    chan *goja.Runtime
+	nMemo  nMemo
+	sig, n callState
+}
+
+// callState tracks failures of one transform, so that a broken n function
+// does not stop signatures.
+type callState struct {
+	timeouts atomic.Int32          // calls in a row that ran out of time
+	broken   atomic.Pointer[error] // set once the function is given up on
+}
+
+func newGojaDecipherer(p *player) *GojaDecipherer {
+	d := &GojaDecipherer{
+		sigName: p.SigName,
+		nName:   p.NName,
+		limits:  DefaultDecipherLimits,
+		vms:     make(chan *goja.Runtime, runtime.GOMAXPROCS(0)),
+	}
+
+	if p.SigName == "" {
+		d.sigErr = errors.New(p.SigErr)
+	} else if prog, err := goja.Compile("sig.js", p.SigCode, false); err != nil {
+		d.sigErr = fmt.Errorf("failed to compile signature code: %v", err)
+	} else {
+		d.programs = append(d.programs, prog)
+	}
+
+	if p.NName == "" {
+		d.nErr = errors.New(p.NErr)
+	} else if prog, err := goja.Compile("n.js", p.NCode, false); err != nil {
+		d.nErr = fmt.Errorf("failed to compile n function code: %v", err)
+	} else {
+		d.programs = append(d.programs, prog)
+	}
+
+	return d
+}
+
+// Signature deciphers the s parameter of a signatureCipher.
+func (d *GojaDecipherer) Signature(ctx context.Context, s string) (string, error) {
+	if d.sigErr != nil {
+		return "", d.sigErr
+	}
+	out, err := d.call(ctx, &d.sig, d.sigName, s)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt signature: %w", err)
+	}
+	return out, nil
+}
+
+// N transforms the n throttling parameter of a stream URL.
+func (d *GojaDecipherer) N(ctx context.Context, n string) (string, error) {
+	if d.nErr != nil {
+		return "", d.nErr
+	}
+	if out, ok := d.nMemo.load(n); ok {
+		return out, nil
+	}
+
+	out, err := d.call(ctx, &d.n, d.nName, n)
+	if err != nil {
+		return "", fmt.Errorf("failed to transform n: %w", err)
+	}
+	if err := checkN(n, out); err != nil {
+		return "", err
+	}
+
+	d.nMemo.store(n, out)
+	return out, nil
+}
+
+// call runs the named function on arg in a pooled VM. The argument is
+// passed as a value, never spliced into JavaScript source. The call is
+// interrupted when ctx ends, when the time budget runs out or when the
+// heap grows by more than Max_alloc.
+func (d *GojaDecipherer) call(ctx context.Context, st *callState, name, arg string) (string, error) {
+	if err := st.broken.Load(); err != nil {
+		return "", *err
+	}
+	if ctx.Err() != nil {
+		return "", ctxError(ctx)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, d.limits.Timeout)
+	defer cancel()
+
+	vm, err := d.getVM(callCtx)
+	if err != nil {
+		return "", d.failed(ctx, st, err)
+	}
+
+	fn, ok := goja.AssertFunction(vm.Get(name))
+	if !ok {
+		return "", fmt.Errorf("%s is not a function", name)
+	}
+
+	stop := d.watch(callCtx, vm)
+	result, err := fn(goja.Undefined(), vm.ToValue(arg))
+	stop()
+	if err != nil {
+		// The VM may be left mid-call, so it is not pooled again
+		return "", d.failed(ctx, st, err)
+	}
+
+	vm.ClearInterrupt()
+	d.putVM(vm)
+	st.timeouts.Store(0)
+	return result.String(), nil
+}
+
+// failed classifies an error from running player code. Only the player is
+// to blame for a stack overflow, for growing the heap too much or for
+// running out of the time budget maxTimeouts times in a row, and only then
+// is the function marked broken, so one bad player costs a few time
+// budgets rather than one per format. The end of ctx says nothing about
+// the player.
+func (d *GojaDecipherer) failed(ctx context.Context, st *callState, err error) error {
+	if ctx.Err() != nil {
+		return ctxError(ctx)
+	}
+
+	var interrupted *goja.InterruptedError
+	var overflow *goja.StackOverflowError
+	switch {
+	case errors.As(err, &interrupted):
+		if e, ok := interrupted.Value().(error); ok {
+			err = e
+		}
+		switch {
+		case errors.Is(err, ErrDecipherLimit):
+		case !errors.Is(err, ErrDecipherTimeout) || st.timeouts.Add(1) < maxTimeouts:
+			return err
+		}
+	case errors.As(err, &overflow):
+		err = fmt.Errorf("%w: call stack deeper than %d", ErrDecipherLimit, d.limits.Max_stack)
+	default:
+		return err
+	}
+
+	st.broken.Store(&err)
+	return err
+}
+
+// allocCheckInterval is how often a running call's heap growth is
+// sampled.
+const allocCheckInterval = 5 * time.Millisecond
+
+// watch interrupts vm when ctx ends or the heap grows by more than
+// Max_alloc while it runs. goja cannot account for the memory of one VM,
+// so the growth is that of the whole heap; other work at the same time
+// counts too, which only makes the limit stricter. The returned function
+// stops watching and waits for the watcher to exit, so no interrupt can
+// arrive after it returns.
+func (d *GojaDecipherer) watch(ctx context.Context, vm *goja.Runtime) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		var tick <-chan time.Time
+		start := heapInUse()
+		if d.limits.Max_alloc > 0 {
+			ticker := time.NewTicker(allocCheckInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				vm.Interrupt(ctxError(ctx))
+				return
+			case <-tick:
+				if heapInUse()-start > d.limits.Max_alloc {
+					vm.Interrupt(fmt.Errorf("%w: heap grew by more than %s", ErrDecipherLimit, abbr(d.limits.Max_alloc)))
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// heapInUse returns the bytes taken by heap objects, live or not yet
+// swept. Unlike runtime.ReadMemStats it does not stop the world.
+func heapInUse() int64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(sample[0].Value.Uint64())
+}
+
+// getVM returns a pooled VM, or a new one with the player code loaded.
+// Loading runs top-level initializers, so it is watched like a call.
+func (d *GojaDecipherer) getVM(ctx context.Context) (*goja.Runtime, error) {
+	select {
+	case vm := <-d.vms:
+		return vm, nil
+	default:
+	}
+
+	vm := goja.New()
+	if d.limits.Max_stack > 0 {
+		vm.SetMaxCallStackSize(d.limits.Max_stack)
+	}
+
+	stop := d.watch(ctx, vm)
+	defer stop()
+	for _, prog := range d.programs {
+		if _, err := vm.RunProgram(prog); err != nil {
+			return nil, fmt.Errorf("failed to execute player code: %w", err)
+		}
+	}
+	return vm, nil
+}
+
+func (d *GojaDecipherer) putVM(vm *goja.Runtime) {
+	select {
+	case d.vms <- vm:
+	default:
+	}
+}
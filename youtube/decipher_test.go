@@ -0,0 +1,155 @@
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixture is a player in testdata/players with known outputs of its
+// transforms, which were not produced by any engine under test.
+type fixture struct {
+	name       string
+	code       string
+	Player_url string            `json:"player_url"`
+	Signatures map[string]string `json:"signatures"`          // input -> deciphered
+	N          map[string]string `json:"n"`                   // input -> transformed
+	Synthetic  bool              `json:"synthetic,omitempty"` // written for the tests rather than recorded
+}
+
+func loadFixtures(t *testing.T) []*fixture {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "players", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures in testdata/players")
+	}
+	sort.Strings(files)
+
+	var fixtures []*fixture
+	for _, file := range files {
+		meta, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := &fixture{name: strings.TrimSuffix(filepath.Base(file), ".json")}
+		if err := json.Unmarshal(meta, f); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		code, err := os.ReadFile(strings.TrimSuffix(file, ".json") + ".js")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.code = string(code)
+		fixtures = append(fixtures, f)
+	}
+	return fixtures
+}
+
+func TestDecipherFixtures(t *testing.T) {
+	for _, f := range loadFixtures(t) {
+		for _, engine := range Engines {
+			t.Run(f.name+"/"+string(engine), func(t *testing.T) {
+				switch engine {
+				case EngineNode, EngineDeno, EngineQuickJS:
+					if _, err := exec.LookPath(string(engine)); err != nil {
+						t.Skipf("%s not installed", engine)
+					}
+				}
+				checkFixture(t, f, engine)
+			})
+		}
+	}
+}
+
+// TestDecipherRealPlayers runs the built-in engines on the players
+// recorded from YouTube, which were not written to fit the extractor.
+func TestDecipherRealPlayers(t *testing.T) {
+	var real []*fixture
+	for _, f := range loadFixtures(t) {
+		if !f.Synthetic {
+			real = append(real, f)
+		}
+	}
+	if len(real) == 0 {
+		t.Skip("no real player in testdata/players; record one with go run ./cmd/ytfixture")
+	}
+	for _, f := range real {
+		for _, engine := range []Engine{EngineGoja, EngineStatic} {
+			t.Run(f.name+"/"+string(engine), func(t *testing.T) {
+				checkFixture(t, f, engine)
+			})
+		}
+	}
+}
+
+// checkFixture extracts the fixture's player afresh and checks what
+// engine makes of the recorded inputs. The static engine is not checked
+// on n, which it does not support.
+func checkFixture(t *testing.T, f *fixture, engine Engine) {
+	t.Helper()
+	ctx := context.Background()
+	p := &player{URL: f.Player_url, Code: f.code}
+	p.extract()
+	if p.Sts == 0 {
+		t.Error("signatureTimestamp not found")
+	}
+	d, err := newDecipherer(p, engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for in, want := range f.Signatures {
+		if got, err := d.Signature(ctx, in); err != nil {
+			t.Errorf("Signature(%q): %v", in, err)
+		} else if got != want {
+			t.Errorf("Signature(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for in, want := range f.N {
+		got, err := d.N(ctx, in)
+		if errors.Is(err, errStaticN) {
+			break
+		}
+		if err != nil {
+			t.Errorf("N(%q): %v", in, err)
+		} else if got != want {
+			t.Errorf("N(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDecipherAllocLimit(t *testing.T) {
+	p := &player{
+		SigName: "sf",
+		SigCode: `var sf=function(a){for(;;)a=a+a;return a};`,
+		NName:   "nf",
+		NCode:   `var nf=function(a){return a.split("").reverse().join("")};`,
+	}
+	d := newGojaDecipherer(p)
+	d.limits.Timeout = 30 * time.Second
+	d.limits.Max_alloc = 64 << 20
+
+	start := time.Now()
+	_, err := d.Signature(context.Background(), "abcdefgh")
+	if !errors.Is(err, ErrDecipherLimit) {
+		t.Fatalf("Signature() error = %v, want ErrDecipherLimit", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Signature() took %s to hit the limit", elapsed)
+	}
+
+	// The n function is unaffected
+	if out, err := d.N(context.Background(), "abc"); err != nil || out != "cba" {
+		t.Errorf("N() = %q, %v, want cba", out, err)
+	}
+}
@@ -0,0 +1,142 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// PlayerReport describes how extraction and deciphering went for one
+// player, for triaging breakage after YouTube rotates its player.
+type PlayerReport struct {
+	Source string `json:"source"`
+	Size   int    `json:"size"`
+
+	Parse_time time.Duration `json:"parse_time"`
+	Parse_err  string        `json:"parse_err,omitempty"`
+
+	// How the regexes fare on their own, whether or not they were needed
+	Regex_pattern int    `json:"regex_pattern"` // 1-based; 0 when none matched
+	Regex_name    string `json:"regex_name,omitempty"`
+	Helper_name   string `json:"helper_name,omitempty"`
+	Helper_found  bool   `json:"helper_found"`
+
+	Extract_time time.Duration `json:"extract_time"`
+	Sts          int           `json:"sts"` // 0 when not found
+	Sig_name     string        `json:"sig_name,omitempty"`
+	Sig_ops      []SigOp       `json:"sig_ops,omitempty"`
+	Sig_err      string        `json:"sig_err,omitempty"`
+	N_name       string        `json:"n_name,omitempty"`
+	N_err        string        `json:"n_err,omitempty"`
+
+	Engine     Engine         `json:"engine"`
+	Setup_time time.Duration  `json:"setup_time"`
+	Setup_err  string         `json:"setup_err,omitempty"`
+	Samples    []SampleResult `json:"samples,omitempty"`
+}
+
+// SampleResult is one transform run on a sample input.
+type SampleResult struct {
+	Kind     string        `json:"kind"` // "signature" or "n"
+	Input    string        `json:"input"`
+	Output   string        `json:"output,omitempty"`
+	Err      string        `json:"err,omitempty"`
+	Skipped  string        `json:"skipped,omitempty"` // why the engine did not run it
+	Duration time.Duration `json:"duration"`
+}
+
+// Sample inputs, shaped like real s and n values
+var (
+	sampleSignatures = []string{sampleInput(104, 0), sampleInput(108, 7)}
+	sampleN          = []string{sampleInput(16, 3), sampleInput(18, 11)}
+)
+
+const sampleAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+func sampleInput(length, seed int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = sampleAlphabet[(i*7+seed)%len(sampleAlphabet)]
+	}
+	return string(b)
+}
+
+// CheckPlayer loads a player from a URL or a local base.js file, bypassing
+// the player cache, and reports how each extraction step went and what
+// engine makes of the sample inputs.
+func CheckPlayer(ctx context.Context, source string, engine Engine) (*PlayerReport, error) {
+	var code string
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		var err error
+		if code, err = fetchPlayerCode(ctx, source); err != nil {
+			return nil, err
+		}
+	} else {
+		b, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		code = string(b)
+	}
+	if engine == "" {
+		engine = EngineGoja
+	}
+
+	r := &PlayerReport{Source: source, Size: len(code), Engine: engine}
+
+	start := time.Now()
+	_, err := parseScope(code)
+	r.Parse_time = time.Since(start)
+	if err != nil {
+		r.Parse_err = err.Error()
+	}
+
+	if name, helper, pattern, err := extractDecryptFunction(code); err == nil {
+		r.Regex_pattern = pattern
+		r.Regex_name = name
+		r.Helper_name = helper
+		if helper != "" {
+			_, err := extractHelperObject(code, helper)
+			r.Helper_found = err == nil
+		}
+	}
+
+	start = time.Now()
+	p := &player{URL: source, Code: code}
+	p.extract()
+	r.Extract_time = time.Since(start)
+	r.Sts = p.Sts
+	r.Sig_name, r.Sig_ops, r.Sig_err = p.SigName, p.SigOps, p.SigErr
+	r.N_name, r.N_err = p.NName, p.NErr
+
+	start = time.Now()
+	d, err := newDecipherer(p, engine)
+	r.Setup_time = time.Since(start)
+	if err != nil {
+		r.Setup_err = err.Error()
+		return r, nil
+	}
+
+	run := func(kind, input string, fn func(context.Context, string) (string, error)) {
+		start := time.Now()
+		out, err := fn(ctx, input)
+		s := SampleResult{Kind: kind, Input: input, Output: out, Duration: time.Since(start)}
+		switch {
+		case errors.Is(err, errStaticN):
+			s.Skipped = err.Error()
+		case err != nil:
+			s.Err = err.Error()
+		}
+		r.Samples = append(r.Samples, s)
+	}
+	for _, s := range sampleSignatures {
+		run("signature", s, d.Signature)
+	}
+	for _, n := range sampleN {
+		run("n", n, d.N)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,25 @@
+package youtube
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPlayerStatic(t *testing.T) {
+	r, err := CheckPlayer(context.Background(), filepath.Join("testdata", "players", "statements.js"), EngineStatic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Setup_err != "" {
+		t.Fatalf("Setup_err = %q", r.Setup_err)
+	}
+	for _, s := range r.Samples {
+		if s.Err != "" {
+			t.Errorf("%s sample %q failed: %s", s.Kind, s.Input, s.Err)
+		}
+		if skipped := s.Skipped != ""; skipped != (s.Kind == "n") {
+			t.Errorf("%s sample skipped = %q", s.Kind, s.Skipped)
+		}
+	}
+}
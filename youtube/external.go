@@ -0,0 +1,171 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// externalRunner evaluates the extracted code and calls the named function
+// on the argument. It works unchanged in node, deno and QuickJS; only
+// reading stdin and writing stdout differ.
+const externalRunner = `function run(input) {
+	var r = JSON.parse(input);
+	try {
+		var f = (0, eval)(r.code + "\n;" + r.name);
+		return JSON.stringify({result: String(f(r.arg))});
+	} catch (e) {
+		return JSON.stringify({error: String(e)});
+	}
+}
+`
+
+// ExternalDecipherer runs the transforms in a local JavaScript runtime,
+// node, deno or qjs, starting one process per call. The code and argument
+// are written to its stdin as JSON and the result read back from stdout.
+// The time budget of DecipherLimits applies, and the allocation limit is
+// passed on as the runtime's heap limit.
+type ExternalDecipherer struct {
+	engine Engine
+	path   string
+	args   []string
+
+	sigName, sigCode string
+	nName, nCode     string
+	sigErr, nErr     error
+	limits           DecipherLimits
+
+	nMemo nMemo
+}
+
+func newExternalDecipherer(p *player, engine Engine) (*ExternalDecipherer, error) {
+	path, err := exec.LookPath(string(engine))
+	if err != nil {
+		return nil, fmt.Errorf("%s not found in PATH", engine)
+	}
+
+	d := &ExternalDecipherer{
+		engine:  engine,
+		path:    path,
+		sigName: p.SigName,
+		sigCode: p.SigCode,
+		nName:   p.NName,
+		nCode:   p.NCode,
+		limits:  DefaultDecipherLimits,
+	}
+	if p.SigName == "" {
+		d.sigErr = errors.New(p.SigErr)
+	}
+	if p.NName == "" {
+		d.nErr = errors.New(p.NErr)
+	}
+
+	heapMB := d.limits.Max_alloc >> 20
+	switch engine {
+	case EngineNode:
+		if heapMB > 0 {
+			d.args = append(d.args, fmt.Sprintf("--max-old-space-size=%d", heapMB))
+		}
+		d.args = append(d.args, "-e", externalRunner+
+			`let d = ""; process.stdin.setEncoding("utf8");
+			process.stdin.on("data", c => d += c);
+			process.stdin.on("end", () => process.stdout.write(run(d)));`)
+	case EngineDeno:
+		d.args = append(d.args, "eval")
+		if heapMB > 0 {
+			d.args = append(d.args, fmt.Sprintf("--v8-flags=--max-old-space-size=%d", heapMB))
+		}
+		d.args = append(d.args, externalRunner+
+			`const d = await new Response(Deno.stdin.readable).text();
+			await Deno.stdout.write(new TextEncoder().encode(run(d)));`)
+	case EngineQuickJS:
+		d.args = append(d.args, "--std")
+		if d.limits.Max_alloc > 0 {
+			d.args = append(d.args, "--memory-limit", fmt.Sprint(d.limits.Max_alloc))
+		}
+		d.args = append(d.args, "-e", externalRunner+`std.out.puts(run(std.in.readAsString()));`)
+	default:
+		return nil, fmt.Errorf("%s is not an external engine", engine)
+	}
+
+	return d, nil
+}
+
+// Signature deciphers the s parameter of a signatureCipher.
+func (d *ExternalDecipherer) Signature(ctx context.Context, s string) (string, error) {
+	if d.sigErr != nil {
+		return "", d.sigErr
+	}
+	out, err := d.call(ctx, d.sigCode, d.sigName, s)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt signature: %w", err)
+	}
+	return out, nil
+}
+
+// N transforms the n throttling parameter of a stream URL.
+func (d *ExternalDecipherer) N(ctx context.Context, n string) (string, error) {
+	if d.nErr != nil {
+		return "", d.nErr
+	}
+	if out, ok := d.nMemo.load(n); ok {
+		return out, nil
+	}
+
+	out, err := d.call(ctx, d.nCode, d.nName, n)
+	if err != nil {
+		return "", fmt.Errorf("failed to transform n: %w", err)
+	}
+	if err := checkN(n, out); err != nil {
+		return "", err
+	}
+
+	d.nMemo.store(n, out)
+	return out, nil
+}
+
+func (d *ExternalDecipherer) call(ctx context.Context, code, name, arg string) (string, error) {
+	input, err := json.Marshal(map[string]string{"code": code, "name": name, "arg": arg})
+	if err != nil {
+		return "", err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, d.limits.Timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(callCtx, d.path, d.args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if callCtx.Err() != nil {
+			return "", ctxError(callCtx)
+		}
+		return "", fmt.Errorf("%s failed: %v: %s", d.engine, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var result struct {
+		Result *string `json:"result"`
+		Error  string  `json:"error"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return "", fmt.Errorf("unexpected output from %s: %q", d.engine, truncateOutput(out))
+	}
+	if result.Result == nil {
+		return "", fmt.Errorf("%s: %s", d.engine, result.Error)
+	}
+	return *result.Result, nil
+}
+
+func truncateOutput(b []byte) string {
+	if len(b) > 200 {
+		return string(b[:200]) + "..."
+	}
+	return string(b)
+}
@@ -0,0 +1,102 @@
+package youtube
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// extractJSONVar finds an assignment of a JavaScript object literal to
+// name in a page, in any of the forms YouTube uses:
+//
+//	var ytInitialPlayerResponse = {...};
+//	window["ytInitialPlayerResponse"] = {...};
+//	ytInitialData = {...};
+//
+// and returns the complete object. Unlike a non-greedy regex it tracks
+// strings and escapes, so a "};" inside a string value does not end the
+// object early.
+func extractJSONVar(htmlContent, name string) ([]byte, error) {
+	re := regexp.MustCompile(`(?:\bvar\s+|\bwindow\[\s*["']|\bwindow\.|\b)` + regexp.QuoteMeta(name) + `(?:["']\s*\])?\s*=\s*\{`)
+
+	for _, loc := range re.FindAllStringIndex(htmlContent, -1) {
+		obj := scanObject(htmlContent, loc[1]-1)
+		if obj == "" {
+			continue
+		}
+		if json.Valid([]byte(obj)) {
+			return []byte(obj), nil
+		}
+	}
+	return nil, fmt.Errorf("could not find %s in page", name)
+}
+
+// scanObject returns the JSON object starting at the '{' at index start,
+// or "" if it is not terminated.
+func scanObject(s string, start int) string {
+	depth := 0
+	inString := false
+	escapeNext := false
+
+	for i := start; i < len(s); i++ {
+		c := s[i]
+
+		if escapeNext {
+			escapeNext = false
+			continue
+		}
+
+		if inString {
+			switch c {
+			case '\\':
+				escapeNext = true
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return s[start : i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// extractPlayerResponse pulls ytInitialPlayerResponse out of a watch page.
+func extractPlayerResponse(htmlContent string) (*playerResponse, error) {
+	b, err := extractJSONVar(htmlContent, "ytInitialPlayerResponse")
+	if err != nil {
+		return nil, err
+	}
+
+	var pr playerResponse
+	if err := json.Unmarshal(b, &pr); err != nil {
+		return nil, fmt.Errorf("failed to parse player response: %v", err)
+	}
+	return &pr, nil
+}
+
+// extractInitialData pulls ytInitialData out of a YouTube page. It holds
+// everything rendered around the player: playlists, channel tabs, search
+// results, chapters.
+func extractInitialData(htmlContent string) (map[string]interface{}, error) {
+	b, err := extractJSONVar(htmlContent, "ytInitialData")
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse initial data: %v", err)
+	}
+	return data, nil
+}
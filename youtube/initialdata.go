@@ -0,0 +1,244 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ytInitialData and browse responses are deeply nested and their layout
+// shifts often, so they are decoded generically and searched for the
+// renderer objects we need rather than mapped onto fixed structs.
+
+// fetchPage downloads a YouTube page as an English-language desktop
+// browser.
+func fetchPage(ctx context.Context, pageURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", ClientWeb.User_agent)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	// Skip the EU cookie consent interstitial.
+	req.Header.Set("Cookie", "CONSENT=YES+cb; SOCS=CAI")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("failed to fetch %s: status %d", pageURL, resp.StatusCode)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// browseContinuation fetches the next page of a browse listing.
+func browseContinuation(ctx context.Context, token string) (map[string]interface{}, error) {
+	body := map[string]interface{}{
+		"context":      ClientWeb.context(),
+		"continuation": token,
+	}
+
+	var data map[string]interface{}
+	if err := innertubeRequest(ctx, "browse", ClientWeb, body, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// browseEntries collects the entries of a browse listing, starting from
+// the page data and following continuations to the end. page extracts the
+// entries of one page; offset is the number of entries on earlier pages.
+func browseEntries(ctx context.Context, data map[string]interface{}, page func(node interface{}, offset int) []Entry) ([]Entry, error) {
+	var entries []Entry
+	node := interface{}(data)
+	seen := map[string]bool{}
+	for {
+		found := page(node, len(entries))
+		entries = append(entries, found...)
+
+		// Stop on a repeated token or an empty page rather than loop
+		// forever on a server that keeps returning the same page.
+		token := continuationToken(node)
+		if token == "" || seen[token] || len(found) == 0 {
+			return entries, nil
+		}
+		seen[token] = true
+
+		var err error
+		if node, err = browseContinuation(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// walk calls fn for every object stored under key anywhere below node.
+// Array elements are visited in order, so list items come out in the
+// order they are displayed. Objects found are not searched further.
+func walk(node interface{}, key string, fn func(map[string]interface{})) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if v, ok := n[key].(map[string]interface{}); ok {
+			fn(v)
+		}
+		for _, k := range sortedKeys(n) {
+			if k != key {
+				walk(n[k], key, fn)
+			}
+		}
+	case []interface{}:
+		for _, v := range n {
+			walk(v, key, fn)
+		}
+	}
+}
+
+// walkAll is like walk but looks for several keys in a single pass, so
+// that objects of different kinds keep their relative order.
+func walkAll(node interface{}, keys []string, fn func(key string, obj map[string]interface{})) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for _, key := range keys {
+			if v, ok := n[key].(map[string]interface{}); ok {
+				fn(key, v)
+				return
+			}
+		}
+		for _, k := range sortedKeys(n) {
+			walkAll(n[k], keys, fn)
+		}
+	case []interface{}:
+		for _, v := range n {
+			walkAll(v, keys, fn)
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+// dig follows a path of object keys (string) and array indices (int).
+func dig(node interface{}, path ...interface{}) interface{} {
+	for _, p := range path {
+		switch k := p.(type) {
+		case string:
+			m, ok := node.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			node = m[k]
+		case int:
+			a, ok := node.([]interface{})
+			if !ok || k < 0 || k >= len(a) {
+				return nil
+			}
+			node = a[k]
+		}
+	}
+	return node
+}
+
+func digString(node interface{}, path ...interface{}) string {
+	switch v := dig(node, path...).(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// textOf flattens a {"simpleText": ...}, {"runs": [...]} or
+// {"content": ...} display string.
+func textOf(node interface{}) string {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		s, _ := node.(string)
+		return s
+	}
+	if s, ok := m["simpleText"].(string); ok {
+		return s
+	}
+	if s, ok := m["content"].(string); ok {
+		return s
+	}
+	var sb strings.Builder
+	if runs, ok := m["runs"].([]interface{}); ok {
+		for _, r := range runs {
+			sb.WriteString(digString(r, "text"))
+		}
+	}
+	return sb.String()
+}
+
+// lastThumbnail returns the last, and by YouTube's ordering largest, URL
+// of a thumbnails or image sources list.
+func lastThumbnail(node interface{}) string {
+	list, _ := node.([]interface{})
+	if len(list) == 0 {
+		return ""
+	}
+	return digString(list[len(list)-1], "url")
+}
+
+// findString returns the first string below node that contains substr.
+func findString(node interface{}, substr string) string {
+	switch n := node.(type) {
+	case string:
+		if strings.Contains(n, substr) {
+			return n
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(n) {
+			if s := findString(n[k], substr); s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		for _, v := range n {
+			if s := findString(v, substr); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// continuationToken returns the first continuation token below node.
+func continuationToken(node interface{}) string {
+	var token string
+	walk(node, "continuationItemRenderer", func(r map[string]interface{}) {
+		if token == "" {
+			token = digString(r, "continuationEndpoint", "continuationCommand", "token")
+		}
+	})
+	return token
+}
+
+// parseClock converts "1:02:03" or "2:03" into seconds.
+func parseClock(s string) int {
+	total := 0
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return total
+}
@@ -0,0 +1,344 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	URL_INNERTUBE = "https://www.youtube.com/youtubei/v1/"
+	URL_IFRAME    = "https://www.youtube.com/iframe_api"
+)
+
+// InnertubeClient describes a client profile sent to the youtubei API.
+// Different clients are served different format lists, so a format
+// missing from one can often be found in another.
+type InnertubeClient struct {
+	Name       string // clientName in the request context
+	Version    string
+	Id         int // X-YouTube-Client-Name header
+	User_agent string
+
+	Device_make, Device_model string
+	Os_name, Os_version       string
+	Android_sdk_version       int
+	Client_screen             string
+	Embed_url                 string
+}
+
+var (
+	ClientWeb = InnertubeClient{
+		Name:       "WEB",
+		Version:    "2.20240726.00.00",
+		Id:         1,
+		User_agent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	}
+	ClientAndroid = InnertubeClient{
+		Name:                "ANDROID",
+		Version:             "19.29.37",
+		Id:                  3,
+		User_agent:          "com.google.android.youtube/19.29.37 (Linux; U; Android 11) gzip",
+		Os_name:             "Android",
+		Os_version:          "11",
+		Android_sdk_version: 30,
+	}
+	ClientIOS = InnertubeClient{
+		Name:         "IOS",
+		Version:      "19.29.1",
+		Id:           5,
+		User_agent:   "com.google.ios.youtube/19.29.1 (iPhone16,2; U; CPU iOS 17_5_1 like Mac OS X;)",
+		Device_make:  "Apple",
+		Device_model: "iPhone16,2",
+		Os_name:      "iPhone",
+		Os_version:   "17.5.1.21F90",
+	}
+	ClientTVEmbedded = InnertubeClient{
+		Name:          "TVHTML5_SIMPLY_EMBEDDED_PLAYER",
+		Version:       "2.0",
+		Id:            85,
+		User_agent:    "Mozilla/5.0 (PlayStation; PlayStation 4/12.00) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.4 Safari/605.1.15",
+		Client_screen: "EMBED",
+		Embed_url:     "https://www.youtube.com/",
+	}
+	ClientWebCreator = InnertubeClient{
+		Name:       "WEB_CREATOR",
+		Version:    "1.20240723.03.00",
+		Id:         62,
+		User_agent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	}
+)
+
+var clientsByName = map[string]InnertubeClient{
+	"web":         ClientWeb,
+	"android":     ClientAndroid,
+	"ios":         ClientIOS,
+	"tv_embedded": ClientTVEmbedded,
+	"web_creator": ClientWebCreator,
+}
+
+// ClientByName looks up a client profile by its lower-case name, e.g.
+// "android" or "tv_embedded".
+func ClientByName(name string) (InnertubeClient, bool) {
+	c, ok := clientsByName[strings.ToLower(strings.TrimSpace(name))]
+	return c, ok
+}
+
+// GetOptions controls how Get fetches video metadata.
+type GetOptions struct {
+	// Clients are queried in order through the Innertube API. Formats
+	// missing from the first response are filled in from later ones.
+	// When empty, the watch page HTML is scraped instead.
+	Clients []InnertubeClient
+
+	// Engine runs the player's signature and n transforms; the default
+	// is EngineGoja.
+	Engine Engine
+}
+
+// GetWithOptions is like Get but lets the caller choose how metadata is
+// fetched.
+func GetWithOptions(video_id string, opts *GetOptions) (Video, error) {
+	return GetWithOptionsContext(context.Background(), video_id, opts)
+}
+
+// GetWithOptionsContext is like GetWithOptions but gives up when ctx is
+// done.
+func GetWithOptionsContext(ctx context.Context, video_id string, opts *GetOptions) (Video, error) {
+	if opts == nil {
+		opts = &GetOptions{}
+	}
+
+	video_id, err := extractId(video_id)
+	if err != nil {
+		return Video{}, err
+	}
+
+	var meta *Video
+	if len(opts.Clients) == 0 {
+		meta, err = getFromPage(ctx, video_id, opts.Engine)
+	} else {
+		meta, err = getFromClients(ctx, video_id, opts.Clients, opts.Engine)
+	}
+	if err != nil {
+		return Video{}, err
+	}
+	meta.opts = *opts
+
+	return *meta, nil
+}
+
+func getFromPage(ctx context.Context, video_id string, engine Engine) (*Video, error) {
+	query, err := fetchMeta(ctx, video_id)
+	if err != nil {
+		return nil, err
+	}
+	return parseMeta(ctx, video_id, query, engine)
+}
+
+func getFromClients(ctx context.Context, video_id string, clients []InnertubeClient, engine Engine) (*Video, error) {
+	// The player comes first: its signatureTimestamp goes into the
+	// requests, so that ciphered formats match the code that deciphers
+	// them. Without it formats that need no deciphering still work.
+	var p *player
+	sts := 0
+	if playerURL, err := fetchPlayerURL(ctx); err == nil {
+		if p, _ = loadPlayer(ctx, playerURL); p != nil {
+			sts = p.Sts
+		}
+	}
+
+	pr, err := fetchPlayerResponses(ctx, video_id, clients, sts)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildVideo(video_id, pr, nil, p, engine)
+}
+
+// fetchPlayerResponses queries each client in turn and merges the
+// results. Video details come from the first client that returns them;
+// formats are merged by itag. sts is sent as the signatureTimestamp when
+// non-zero.
+func fetchPlayerResponses(ctx context.Context, video_id string, clients []InnertubeClient, sts int) (*playerResponse, error) {
+	var merged *playerResponse
+	var errs []error
+	var playErr error
+	seen := map[int]bool{}
+
+	for _, client := range clients {
+		pr, err := fetchPlayerResponse(ctx, video_id, client, sts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", client.Name, err))
+			continue
+		}
+		if err := pr.PlayabilityStatus.err(); err != nil {
+			// Another client may still be allowed to play it.
+			if playErr == nil {
+				playErr = err
+			}
+			continue
+		}
+
+		if merged == nil {
+			first := *pr
+			merged = &first
+			merged.StreamingData.Formats = nil
+			merged.StreamingData.AdaptiveFormats = nil
+		} else if merged.VideoDetails.VideoID == "" {
+			merged.VideoDetails = pr.VideoDetails
+		}
+
+		for _, f := range pr.StreamingData.Formats {
+			if !seen[f.Itag] {
+				seen[f.Itag] = true
+				merged.StreamingData.Formats = append(merged.StreamingData.Formats, f)
+			}
+		}
+		for _, f := range pr.StreamingData.AdaptiveFormats {
+			if !seen[f.Itag] {
+				seen[f.Itag] = true
+				merged.StreamingData.AdaptiveFormats = append(merged.StreamingData.AdaptiveFormats, f)
+			}
+		}
+	}
+
+	if merged == nil {
+		if playErr != nil {
+			return nil, playErr
+		}
+		return nil, errors.Join(errs...)
+	}
+	return merged, nil
+}
+
+// fetchPlayerResponse POSTs to the youtubei/v1/player endpoint as the
+// given client.
+func fetchPlayerResponse(ctx context.Context, video_id string, client InnertubeClient, sts int) (*playerResponse, error) {
+	playback := map[string]interface{}{
+		"html5Preference": "HTML5_PREF_WANTS",
+	}
+	if sts > 0 {
+		playback["signatureTimestamp"] = sts
+	}
+	body := map[string]interface{}{
+		"context":        client.context(),
+		"videoId":        video_id,
+		"contentCheckOk": true,
+		"racyCheckOk":    true,
+		"playbackContext": map[string]interface{}{
+			"contentPlaybackContext": playback,
+		},
+	}
+
+	var pr playerResponse
+	if err := innertubeRequest(ctx, "player", client, body, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// context builds the "context" object of an Innertube request body.
+func (c InnertubeClient) context() map[string]interface{} {
+	client := map[string]interface{}{
+		"clientName":    c.Name,
+		"clientVersion": c.Version,
+		"hl":            "en",
+		"gl":            "US",
+		"userAgent":     c.User_agent,
+	}
+	if c.Device_make != "" {
+		client["deviceMake"] = c.Device_make
+	}
+	if c.Device_model != "" {
+		client["deviceModel"] = c.Device_model
+	}
+	if c.Os_name != "" {
+		client["osName"] = c.Os_name
+		client["osVersion"] = c.Os_version
+	}
+	if c.Android_sdk_version != 0 {
+		client["androidSdkVersion"] = c.Android_sdk_version
+	}
+	if c.Client_screen != "" {
+		client["clientScreen"] = c.Client_screen
+	}
+
+	ctx := map[string]interface{}{"client": client}
+	if c.Embed_url != "" {
+		ctx["thirdParty"] = map[string]interface{}{"embedUrl": c.Embed_url}
+	}
+	return ctx
+}
+
+// innertubeRequest POSTs body to a youtubei/v1 endpoint and decodes the
+// JSON response into out.
+func innertubeRequest(ctx context.Context, endpoint string, client InnertubeClient, body interface{}, out interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", URL_INNERTUBE+endpoint+"?prettyPrint=false", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", client.User_agent)
+	req.Header.Set("Origin", "https://www.youtube.com")
+	req.Header.Set("X-YouTube-Client-Name", fmt.Sprint(client.Id))
+	req.Header.Set("X-YouTube-Client-Version", client.Version)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("innertube %s request failed: status %d", endpoint, resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse innertube %s response: %v", endpoint, err)
+	}
+	return nil
+}
+
+// fetchPlayerURL finds the current player JavaScript URL without loading
+// a watch page, using the player hash referenced by the iframe API.
+func fetchPlayerURL(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", URL_IFRAME, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", ClientWeb.User_agent)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("failed to fetch iframe api: status %d", resp.StatusCode)
+	}
+
+	b, _ := ioutil.ReadAll(resp.Body)
+	re := regexp.MustCompile(`player\\?/([0-9a-fA-F]{8})\\?/`)
+	matches := re.FindSubmatch(b)
+	if len(matches) < 2 {
+		return "", errors.New("could not find player hash in iframe api")
+	}
+	return fmt.Sprintf("https://www.youtube.com/s/player/%s/player_ias.vflset/en_US/base.js", matches[1]), nil
+}
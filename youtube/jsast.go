@@ -0,0 +1,502 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/parser"
+)
+
+// jsScope indexes the top-level declarations of a parsed player, both at
+// file level and inside the (function(g){...})(_yt_player) wrapper.
+type jsScope struct {
+	src   string
+	decls map[string][]jsDecl
+}
+
+// jsDecl is one definition of a top-level name: a var binding, a plain
+// assignment or a function declaration.
+type jsDecl struct {
+	start, end int // source range of the definition's value
+	value      ast.Node
+	fn         *ast.FunctionLiteral // set when the value is a function
+	statement  bool                 // a function declaration, emitted as is
+}
+
+// parseScope parses player code and indexes its top-level declarations.
+func parseScope(code string) (*jsScope, error) {
+	program, err := parser.ParseFile(nil, "base.js", code, parser.IgnoreRegExpErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &jsScope{src: code, decls: make(map[string][]jsDecl)}
+	for _, stmt := range program.Body {
+		s.addStatement(stmt)
+		if body := wrapperBody(stmt); body != nil {
+			for _, inner := range body.List {
+				s.addStatement(inner)
+			}
+		}
+	}
+	return s, nil
+}
+
+// wrapperBody returns the body of an immediately invoked function, as in
+// (function(g){...})(x) or (function(){...}).call(this).
+func wrapperBody(stmt ast.Statement) *ast.BlockStatement {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil
+	}
+	call, ok := es.Expression.(*ast.CallExpression)
+	if !ok {
+		return nil
+	}
+	callee := call.Callee
+	if dot, ok := callee.(*ast.DotExpression); ok && dot.Identifier.Name == "call" {
+		callee = dot.Left
+	}
+	if fn, ok := callee.(*ast.FunctionLiteral); ok {
+		return fn.Body
+	}
+	return nil
+}
+
+func (s *jsScope) addStatement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.VariableStatement:
+		for _, b := range stmt.List {
+			if id, ok := b.Target.(*ast.Identifier); ok && b.Initializer != nil {
+				s.add(string(id.Name), b.Initializer, false)
+			}
+		}
+	case *ast.LexicalDeclaration:
+		for _, b := range stmt.List {
+			if id, ok := b.Target.(*ast.Identifier); ok && b.Initializer != nil {
+				s.add(string(id.Name), b.Initializer, false)
+			}
+		}
+	case *ast.FunctionDeclaration:
+		if stmt.Function.Name != nil {
+			s.add(string(stmt.Function.Name.Name), stmt.Function, true)
+		}
+	case *ast.ExpressionStatement:
+		exprs := []ast.Expression{stmt.Expression}
+		if seq, ok := stmt.Expression.(*ast.SequenceExpression); ok {
+			exprs = seq.Sequence
+		}
+		for _, e := range exprs {
+			assign, ok := e.(*ast.AssignExpression)
+			if !ok {
+				continue
+			}
+			if id, ok := assign.Left.(*ast.Identifier); ok {
+				s.add(string(id.Name), assign.Right, false)
+			}
+		}
+	}
+}
+
+func (s *jsScope) add(name string, value ast.Node, statement bool) {
+	d := jsDecl{
+		start:     offset(value.Idx0()),
+		end:       offset(value.Idx1()),
+		value:     value,
+		statement: statement,
+	}
+	d.fn, _ = value.(*ast.FunctionLiteral)
+	if d.start < 0 || d.end > len(s.src) || d.start >= d.end {
+		return
+	}
+	s.decls[name] = append(s.decls[name], d)
+}
+
+// offset converts a parser position to a byte offset; files parsed
+// without a FileSet start at 1.
+func offset(idx file.Idx) int {
+	return int(idx) - 1
+}
+
+// findSigFunction returns the name of the signature transform: a function
+// of one argument that splits it into an array first and returns the
+// array joined back into a string.
+func (s *jsScope) findSigFunction() (string, error) {
+	var names []string
+	for name, decls := range s.decls {
+		for _, d := range decls {
+			if d.fn != nil && isSplitJoin(d.fn) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	if len(names) == 0 {
+		return "", errors.New("could not find decryption function")
+	}
+
+	// Prefer the earliest definition so the choice is stable
+	sort.Slice(names, func(i, j int) bool {
+		return s.decls[names[i]][0].start < s.decls[names[j]][0].start
+	})
+	return names[0], nil
+}
+
+// isSplitJoin matches function(a){a=a.split("");X.y(a,1);...;return a.join("")}.
+// The split may also open a sequence, as in a=a.split(""),X.y(a,1).
+func isSplitJoin(fn *ast.FunctionLiteral) bool {
+	if fn.ParameterList == nil || len(fn.ParameterList.List) != 1 || fn.Body == nil {
+		return false
+	}
+	id, ok := fn.ParameterList.List[0].Target.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+	param := string(id.Name)
+	body := fn.Body.List
+	if len(body) < 2 {
+		return false
+	}
+
+	first, ok := body[0].(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	expr := first.Expression
+	calls := len(body) - 2
+	if seq, ok := expr.(*ast.SequenceExpression); ok {
+		expr = seq.Sequence[0]
+		calls += len(seq.Sequence) - 1
+	}
+	if calls == 0 {
+		// A bare split and join changes nothing
+		return false
+	}
+	assign, ok := expr.(*ast.AssignExpression)
+	if !ok || !isIdent(assign.Left, param) || !isMethodCall(assign.Right, param, "split") {
+		return false
+	}
+
+	last, ok := body[len(body)-1].(*ast.ReturnStatement)
+	if !ok || !isMethodCall(last.Argument, param, "join") {
+		return false
+	}
+
+	// Everything in between shuffles the array through helper calls
+	for _, stmt := range body[1 : len(body)-1] {
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			return false
+		}
+		if _, ok := es.Expression.(*ast.CallExpression); !ok {
+			if _, ok := es.Expression.(*ast.SequenceExpression); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isIdent(e ast.Expression, name string) bool {
+	id, ok := e.(*ast.Identifier)
+	return ok && string(id.Name) == name
+}
+
+// isMethodCall matches obj.method(...) with a single argument.
+func isMethodCall(e ast.Expression, obj, method string) bool {
+	call, ok := e.(*ast.CallExpression)
+	if !ok || len(call.ArgumentList) != 1 {
+		return false
+	}
+	dot, ok := call.Callee.(*ast.DotExpression)
+	return ok && isIdent(dot.Left, obj) && string(dot.Identifier.Name) == method
+}
+
+// sigOps describes the signature transform name as a list of operations,
+// by classifying the helper methods it calls. It fails if the function
+// does anything the operations cannot express.
+func (s *jsScope) sigOps(name string) ([]SigOp, error) {
+	decls := s.decls[name]
+	if len(decls) == 0 || decls[0].fn == nil || !isSplitJoin(decls[0].fn) {
+		return nil, fmt.Errorf("%s is not a signature transform", name)
+	}
+	body := decls[0].fn.Body.List
+
+	// The split may be followed by calls in the same statement
+	var calls []ast.Expression
+	first := body[0].(*ast.ExpressionStatement).Expression
+	if seq, ok := first.(*ast.SequenceExpression); ok {
+		calls = append(calls, seq.Sequence[1:]...)
+	}
+	for _, stmt := range body[1 : len(body)-1] {
+		expr := stmt.(*ast.ExpressionStatement).Expression
+		if seq, ok := expr.(*ast.SequenceExpression); ok {
+			calls = append(calls, seq.Sequence...)
+		} else {
+			calls = append(calls, expr)
+		}
+	}
+
+	var ops []SigOp
+	for _, e := range calls {
+		call, ok := e.(*ast.CallExpression)
+		if !ok {
+			return nil, fmt.Errorf("unexpected expression in %s", name)
+		}
+		obj, method, ok := memberName(call.Callee)
+		if !ok {
+			return nil, fmt.Errorf("unexpected call in %s", name)
+		}
+		kind, err := s.helperKind(obj, method)
+		if err != nil {
+			return nil, err
+		}
+
+		op := SigOp{Kind: kind}
+		if kind != OpReverse && len(call.ArgumentList) > 1 {
+			lit, ok := call.ArgumentList[1].(*ast.NumberLiteral)
+			if !ok {
+				return nil, fmt.Errorf("non-constant argument to %s.%s", obj, method)
+			}
+			switch v := lit.Value.(type) {
+			case int64:
+				op.Arg = int(v)
+			case float64:
+				op.Arg = int(v)
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// memberName splits a callee of the form obj.method or obj["method"].
+func memberName(callee ast.Expression) (obj, method string, ok bool) {
+	switch c := callee.(type) {
+	case *ast.DotExpression:
+		if id, ok := c.Left.(*ast.Identifier); ok {
+			return string(id.Name), string(c.Identifier.Name), true
+		}
+	case *ast.BracketExpression:
+		id, ok := c.Left.(*ast.Identifier)
+		key, ok2 := c.Member.(*ast.StringLiteral)
+		if ok && ok2 {
+			return string(id.Name), string(key.Value), true
+		}
+	}
+	return "", "", false
+}
+
+// helperKind classifies a method of the signature helper object by what
+// its body does to the array.
+func (s *jsScope) helperKind(obj, method string) (string, error) {
+	for _, d := range s.decls[obj] {
+		lit, ok := d.value.(*ast.ObjectLiteral)
+		if !ok {
+			continue
+		}
+		for _, prop := range lit.Value {
+			keyed, ok := prop.(*ast.PropertyKeyed)
+			if !ok || keyed.Computed || propertyKey(keyed.Key) != method {
+				continue
+			}
+			fn, ok := keyed.Value.(*ast.FunctionLiteral)
+			if !ok {
+				break
+			}
+			src := s.src[offset(fn.Idx0()):offset(fn.Idx1())]
+			switch {
+			case strings.Contains(src, "reverse"):
+				return OpReverse, nil
+			case strings.Contains(src, "splice"):
+				return OpSplice, nil
+			case strings.Contains(src, "%"):
+				return OpSwap, nil
+			}
+			return "", fmt.Errorf("unrecognised helper %s.%s", obj, method)
+		}
+	}
+	return "", fmt.Errorf("could not find helper %s.%s", obj, method)
+}
+
+func propertyKey(key ast.Expression) string {
+	switch k := key.(type) {
+	case *ast.StringLiteral:
+		return string(k.Value)
+	case *ast.Identifier:
+		return string(k.Name)
+	}
+	return ""
+}
+
+// findFunctionWithString returns the name of the first top-level function
+// containing the string literal lit.
+func (s *jsScope) findFunctionWithString(lit string) string {
+	best, bestStart := "", -1
+	for name, decls := range s.decls {
+		for _, d := range decls {
+			if d.fn == nil || !strings.Contains(s.src[d.start:d.end], lit) {
+				continue
+			}
+			found := false
+			inspect(d.fn, func(n ast.Node) bool {
+				if sl, ok := n.(*ast.StringLiteral); ok && strings.Contains(string(sl.Value), lit) {
+					found = true
+				}
+				return !found
+			})
+			if found && (bestStart < 0 || d.start < bestStart) {
+				best, bestStart = name, d.start
+			}
+		}
+	}
+	return best
+}
+
+// code returns JavaScript defining name together with every top-level
+// name it references, directly or through other definitions, in source
+// order. Non-function initializers are wrapped in try/catch, since some
+// touch browser globals that do not exist outside the player.
+func (s *jsScope) code(name string) (string, error) {
+	if _, ok := s.decls[name]; !ok {
+		return "", fmt.Errorf("could not find function definition: %s", name)
+	}
+
+	needed := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, d := range s.decls[n] {
+			for ref := range freeNames(d.value) {
+				if _, ok := s.decls[ref]; ok && !needed[ref] {
+					needed[ref] = true
+					queue = append(queue, ref)
+				}
+			}
+		}
+	}
+
+	type def struct {
+		name string
+		jsDecl
+	}
+	var defs []def
+	for n := range needed {
+		for _, d := range s.decls[n] {
+			defs = append(defs, def{n, d})
+		}
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].start < defs[j].start })
+
+	var b strings.Builder
+	for _, d := range defs {
+		src := s.src[d.start:d.end]
+		switch {
+		case d.statement:
+			b.WriteString(src)
+		case d.fn != nil:
+			fmt.Fprintf(&b, "var %s=%s;", d.name, src)
+		default:
+			fmt.Fprintf(&b, "try{var %s=%s;}catch(e){}", d.name, src)
+		}
+		b.WriteString("\n")
+	}
+
+	// Position ranges are not exact for every node type; make sure what
+	// was cut out still parses on its own.
+	if _, err := parser.ParseFile(nil, "", b.String(), parser.IgnoreRegExpErrors); err != nil {
+		return "", fmt.Errorf("could not isolate %s: %v", name, err)
+	}
+	return b.String(), nil
+}
+
+// freeNames returns the identifiers n reads that it does not declare
+// itself. Scoping is approximate: a name declared anywhere inside n is
+// treated as local throughout.
+func freeNames(n ast.Node) map[string]bool {
+	used := make(map[string]bool)
+	declared := make(map[string]bool)
+
+	var visit func(ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.DotExpression:
+			// The property name is not a reference
+			inspect(n.Left, visit)
+			return false
+		case *ast.PropertyKeyed:
+			if n.Computed {
+				inspect(n.Key, visit)
+			}
+			inspect(n.Value, visit)
+			return false
+		case *ast.Binding:
+			if id, ok := n.Target.(*ast.Identifier); ok {
+				declared[string(id.Name)] = true
+			}
+		case *ast.CatchStatement:
+			if id, ok := n.Parameter.(*ast.Identifier); ok {
+				declared[string(id.Name)] = true
+			}
+		case *ast.FunctionLiteral:
+			if n.Name != nil {
+				declared[string(n.Name.Name)] = true
+			}
+		case *ast.Identifier:
+			used[string(n.Name)] = true
+		}
+		return true
+	}
+	inspect(n, visit)
+
+	for name := range declared {
+		delete(used, name)
+	}
+	return used
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// inspect calls fn for each node of the tree rooted at n, depth first,
+// skipping the children of nodes for which fn returns false. goja's ast
+// package has no walker, so the tree is traversed by reflection.
+func inspect(n ast.Node, fn func(ast.Node) bool) {
+	if n == nil {
+		return
+	}
+	inspectValue(reflect.ValueOf(n), fn)
+}
+
+func inspectValue(v reflect.Value, fn func(ast.Node) bool) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if !v.IsNil() {
+			inspectValue(v.Elem(), fn)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			inspectValue(v.Index(i), fn)
+		}
+	case reflect.Struct:
+		if v.CanAddr() && v.Addr().Type().Implements(nodeType) {
+			if !fn(v.Addr().Interface().(ast.Node)) {
+				return
+			}
+		}
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			// DeclarationList repeats bindings already in the body
+			if !f.IsExported() || f.Name == "DeclarationList" {
+				continue
+			}
+			inspectValue(v.Field(i), fn)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package youtube
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSigOps(t *testing.T) {
+	const helper = `var Xy={ab:function(a,b){a.splice(0,b)},cd:function(a){a.reverse()},` +
+		`ef:function(a,b){var c=a[0];a[0]=a[b%a.length];a[b%a.length]=c}};`
+	want := []SigOp{{OpSplice, 3}, {OpReverse, 0}, {OpSwap, 41}}
+
+	tests := []struct {
+		name, code string
+	}{
+		{"statements", `Mt=function(a){a=a.split("");Xy.ab(a,3);Xy.cd(a,1);Xy.ef(a,41);return a.join("")};`},
+		{"split sequence", `Mt=function(a){a=a.split(""),Xy.ab(a,3);Xy.cd(a,1);Xy.ef(a,41);return a.join("")};`},
+		{"one sequence", `Mt=function(a){a=a.split(""),Xy.ab(a,3),Xy.cd(a,1),Xy.ef(a,41);return a.join("")};`},
+		{"mixed", `Mt=function(a){a=a.split(""),Xy.ab(a,3);Xy.cd(a,1),Xy.ef(a,41);return a.join("")};`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A split and join with nothing between is not a candidate
+			s, err := parseScope(helper + `function sj(a){a=a.split("");return a.join("")}` + tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, err := s.findSigFunction()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != "Mt" {
+				t.Fatalf("findSigFunction() = %q, want Mt", name)
+			}
+			ops, err := s.sigOps(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ops, want) {
+				t.Errorf("sigOps() = %v, want %v", ops, want)
+			}
+		})
+	}
+}
+
+func TestSigOpsRejects(t *testing.T) {
+	const helper = `var Xy={ab:function(a,b){a.splice(0,b)}};`
+	for _, code := range []string{
+		`Mt=function(a){a=a.split(""),a.push(1);return a.join("")};`,
+		`Mt=function(a){a=a.split("");Xy.ab(a,b);return a.join("")};`,
+		`Mt=function(a){a=a.split("");Xy.zz(a,1);return a.join("")};`,
+	} {
+		s, err := parseScope(helper + code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ops, err := s.sigOps("Mt"); err == nil {
+			t.Errorf("sigOps(%s) = %v, want an error", code, ops)
+		}
+	}
+}
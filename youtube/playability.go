@@ -0,0 +1,120 @@
+package youtube
+
+import (
+	"errors"
+	"strings"
+)
+
+// Sentinel errors for videos YouTube refuses to play. Get returns them
+// wrapped in a *PlayabilityError, so match them with errors.Is.
+var (
+	ErrAgeRestricted  = errors.New("video is age restricted")
+	ErrLoginRequired  = errors.New("video requires login")
+	ErrPrivate        = errors.New("video is private")
+	ErrMembersOnly    = errors.New("video is members-only")
+	ErrRegionBlocked  = errors.New("video is not available in this country")
+	ErrLiveNotStarted = errors.New("live stream has not started")
+	ErrUnavailable    = errors.New("video is unavailable")
+)
+
+// PlayabilityError carries YouTube's own explanation of why a video
+// cannot be played.
+type PlayabilityError struct {
+	Status    string // playabilityStatus.status, e.g. LOGIN_REQUIRED
+	Reason    string
+	Subreason string
+	err       error
+}
+
+func (e *PlayabilityError) Error() string {
+	msg := e.Reason
+	if msg == "" {
+		msg = e.err.Error()
+	}
+	if e.Subreason != "" {
+		msg += ": " + e.Subreason
+	}
+	return msg
+}
+
+func (e *PlayabilityError) Unwrap() error {
+	return e.err
+}
+
+type playabilityStatus struct {
+	Status      string `json:"status"`
+	Reason      string `json:"reason"`
+	ErrorScreen struct {
+		PlayerErrorMessageRenderer struct {
+			Reason    text `json:"reason"`
+			Subreason text `json:"subreason"`
+		} `json:"playerErrorMessageRenderer"`
+	} `json:"errorScreen"`
+}
+
+// text is the {"simpleText": ...} or {"runs": [...]} object YouTube uses
+// for display strings.
+type text struct {
+	SimpleText string `json:"simpleText"`
+	Runs       []struct {
+		Text string `json:"text"`
+	} `json:"runs"`
+}
+
+func (t text) String() string {
+	if t.SimpleText != "" {
+		return t.SimpleText
+	}
+	var sb strings.Builder
+	for _, r := range t.Runs {
+		sb.WriteString(r.Text)
+	}
+	return sb.String()
+}
+
+// err maps the playability status to a *PlayabilityError, or nil if the
+// video is playable.
+func (p *playabilityStatus) err() error {
+	if p.Status == "" || p.Status == "OK" {
+		return nil
+	}
+
+	reason := p.Reason
+	if reason == "" {
+		reason = p.ErrorScreen.PlayerErrorMessageRenderer.Reason.String()
+	}
+	subreason := p.ErrorScreen.PlayerErrorMessageRenderer.Subreason.String()
+	lower := strings.ToLower(reason + " " + subreason)
+
+	var err error
+	switch p.Status {
+	case "AGE_CHECK_REQUIRED", "AGE_VERIFICATION_REQUIRED", "CONTENT_CHECK_REQUIRED":
+		err = ErrAgeRestricted
+	case "LIVE_STREAM_OFFLINE":
+		err = ErrLiveNotStarted
+	case "LOGIN_REQUIRED":
+		switch {
+		case strings.Contains(lower, "confirm your age") || strings.Contains(lower, "inappropriate"):
+			err = ErrAgeRestricted
+		case strings.Contains(lower, "private"):
+			err = ErrPrivate
+		default:
+			err = ErrLoginRequired
+		}
+	default: // UNPLAYABLE, ERROR
+		switch {
+		case strings.Contains(lower, "private"):
+			err = ErrPrivate
+		case strings.Contains(lower, "members") || strings.Contains(lower, "join this channel"):
+			err = ErrMembersOnly
+		case strings.Contains(lower, "country"):
+			err = ErrRegionBlocked
+		case strings.Contains(lower, "premiere") || strings.Contains(lower, "live event will begin"):
+			err = ErrLiveNotStarted
+		default:
+			err = ErrUnavailable
+		}
+	}
+
+	return &PlayabilityError{Status: p.Status, Reason: reason, Subreason: subreason, err: err}
+}
@@ -0,0 +1,284 @@
+package youtube
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// player is a base.js together with the signature and n transform code
+// extracted from it. Extraction errors are kept so that a cached player
+// reports the same failure without being parsed again.
+type player struct {
+	URL     string `json:"url"`
+	Code    string `json:"-"`
+	Version int    `json:"version"`       // extractVersion at the time of extraction
+	Sts     int    `json:"sts,omitempty"` // signatureTimestamp; 0 when not found
+
+	SigName string  `json:"sig_name,omitempty"`
+	SigCode string  `json:"sig_code,omitempty"` // helper object and function
+	SigErr  string  `json:"sig_err,omitempty"`
+	SigOps  []SigOp `json:"sig_ops,omitempty"` // for StaticDecipherer
+	NName   string  `json:"n_name,omitempty"`
+	NCode   string  `json:"n_code,omitempty"`
+	NErr    string  `json:"n_err,omitempty"`
+}
+
+// PlayerCache keeps downloaded players and their extracted code on disk,
+// keyed by the player hash in the jsUrl, so batch jobs fetch and parse
+// each base.js once.
+type PlayerCache struct {
+	Dir      string
+	Max_age  time.Duration // entries unused for longer are evicted
+	Max_size int64         // total bytes kept; oldest entries go first
+	Disabled bool
+}
+
+// extractVersion is bumped whenever extraction changes, so that players
+// cached by an older version are extracted again.
+const extractVersion = 4
+
+var DefaultPlayerCache = newDefaultPlayerCache()
+
+func newDefaultPlayerCache() *PlayerCache {
+	c := &PlayerCache{
+		Max_age:  7 * 24 * time.Hour,
+		Max_size: 64 << 20,
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		c.Disabled = true
+		return c
+	}
+	c.Dir = filepath.Join(dir, "ytdl", "players")
+	return c
+}
+
+var stsRe = regexp.MustCompile(`(?:signatureTimestamp|sts)\s*:\s*(\d{5})`)
+
+var playerKeyRe = regexp.MustCompile(`/s/player/([0-9a-zA-Z_-]+)/([0-9a-zA-Z_.-]+)/`)
+
+// playerKey names a cache entry after the player hash and variant, e.g.
+// "1f8742dc-player_ias.vflset".
+func playerKey(playerURL string) string {
+	if m := playerKeyRe.FindStringSubmatch(playerURL); m != nil {
+		return m[1] + "-" + m[2]
+	}
+	sum := sha1.Sum([]byte(playerURL))
+	return hex.EncodeToString(sum[:8])
+}
+
+// loadPlayer returns the player at playerURL, from the cache if possible.
+func loadPlayer(ctx context.Context, playerURL string) (*player, error) {
+	c := DefaultPlayerCache
+	key := playerKey(playerURL)
+
+	if p, ok := c.get(key); ok {
+		return p, nil
+	}
+
+	code, err := fetchPlayerCode(ctx, playerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &player{URL: playerURL, Code: code}
+	p.extract()
+	c.put(key, p)
+	return p, nil
+}
+
+// extract locates the signature timestamp and the signature and n
+// transform functions in the code.
+// The player is parsed so that the functions and everything they
+// reference are found structurally; the regexes remain as a fallback for
+// code the parser rejects.
+func (p *player) extract() {
+	p.Version = extractVersion
+	if m := stsRe.FindStringSubmatch(p.Code); m != nil {
+		p.Sts, _ = strconv.Atoi(m[1])
+	}
+	scope, _ := parseScope(p.Code)
+
+	if funcName, funcCode, err := extractSigFunction(p.Code, scope); err != nil {
+		p.SigErr = err.Error()
+	} else {
+		p.SigName = funcName
+		p.SigCode = funcCode
+		if scope != nil {
+			p.SigOps, _ = scope.sigOps(funcName)
+		}
+	}
+
+	if funcName, funcCode, err := extractNFunction(p.Code, scope); err != nil {
+		p.NErr = err.Error()
+	} else {
+		p.NName = funcName
+		p.NCode = funcCode
+	}
+}
+
+// SignatureTimestamp returns the signatureTimestamp of the current player,
+// which Innertube player requests must carry for ciphered formats to
+// match the decipher code.
+func SignatureTimestamp(ctx context.Context) (int, error) {
+	playerURL, err := fetchPlayerURL(ctx)
+	if err != nil {
+		return 0, err
+	}
+	p, err := loadPlayer(ctx, playerURL)
+	if err != nil {
+		return 0, err
+	}
+	if p.Sts == 0 {
+		return 0, errors.New("signatureTimestamp not found in player")
+	}
+	return p.Sts, nil
+}
+
+func (c *PlayerCache) get(key string) (*player, bool) {
+	if c.Disabled || c.Dir == "" {
+		return nil, false
+	}
+
+	dir := filepath.Join(c.Dir, key)
+	meta, err := os.ReadFile(filepath.Join(dir, "player.json"))
+	if err != nil {
+		return nil, false
+	}
+	code, err := os.ReadFile(filepath.Join(dir, "base.js"))
+	if err != nil {
+		return nil, false
+	}
+
+	var p player
+	if err := json.Unmarshal(meta, &p); err != nil || p.Version != extractVersion {
+		return nil, false
+	}
+	p.Code = string(code)
+
+	// Mark as recently used for eviction
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+	return &p, true
+}
+
+func (c *PlayerCache) put(key string, p *player) error {
+	if c.Disabled || c.Dir == "" {
+		return nil
+	}
+
+	dir := filepath.Join(c.Dir, key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "base.js"), []byte(p.Code)); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "player.json"), meta); err != nil {
+		return err
+	}
+
+	return c.evict()
+}
+
+// evict removes entries older than Max_age, then the least recently used
+// ones until the cache fits in Max_size.
+func (c *PlayerCache) evict() error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var kept []entry
+	var total int64
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		path := filepath.Join(c.Dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if c.Max_age > 0 && time.Since(info.ModTime()) > c.Max_age {
+			os.RemoveAll(path)
+			continue
+		}
+		size := dirSize(path)
+		kept = append(kept, entry{path, size, info.ModTime()})
+		total += size
+	}
+
+	if c.Max_size <= 0 {
+		return nil
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].modTime.Before(kept[j].modTime) })
+	for _, e := range kept {
+		if total <= c.Max_size {
+			break
+		}
+		os.RemoveAll(e.path)
+		total -= e.size
+	}
+	return nil
+}
+
+// Clear removes every cached player.
+func (c *PlayerCache) Clear() error {
+	if c.Dir == "" {
+		return nil
+	}
+	return os.RemoveAll(c.Dir)
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// writeFileAtomic writes through a temporary file so that concurrent
+// readers never see a partial player.
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
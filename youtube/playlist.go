@@ -0,0 +1,163 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	URL_PLAYLIST = "https://www.youtube.com/playlist?list="
+)
+
+// Availability values of an Entry.
+const (
+	AvailabilityPublic      = "public"
+	AvailabilityPrivate     = "private"
+	AvailabilityDeleted     = "deleted"
+	AvailabilityUnavailable = "unavailable"
+)
+
+// Entry is a video listed on a playlist, channel tab or search page. Its
+// Id can be passed straight to Get.
+type Entry struct {
+	Index          int // 1-based position in the listing
+	Id, Title      string
+	Author         string
+	Length_seconds int
+	Availability   string
+}
+
+type Playlist struct {
+	Id, Title, Owner, Description string
+	Entries                       []Entry
+}
+
+// GetPlaylist fetches a playlist and all of its entries, following
+// continuations past the first page of 100.
+func GetPlaylist(idOrURL string) (*Playlist, error) {
+	return GetPlaylistContext(context.Background(), idOrURL)
+}
+
+// GetPlaylistContext is like GetPlaylist but gives up when ctx is done.
+func GetPlaylistContext(ctx context.Context, idOrURL string) (*Playlist, error) {
+	id, err := extractPlaylistId(idOrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := fetchPage(ctx, URL_PLAYLIST+id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := extractInitialData(page)
+	if err != nil {
+		return nil, err
+	}
+
+	if alert := playlistAlert(data); alert != "" {
+		return nil, fmt.Errorf("playlist %s: %s", id, alert)
+	}
+
+	playlist := &Playlist{
+		Id:          id,
+		Title:       digString(data, "metadata", "playlistMetadataRenderer", "title"),
+		Description: digString(data, "metadata", "playlistMetadataRenderer", "description"),
+		Owner:       playlistOwner(data),
+	}
+
+	playlist.Entries, err = browseEntries(ctx, data, playlistEntries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist continuation: %w", err)
+	}
+
+	if len(playlist.Entries) == 0 {
+		return nil, errors.New("playlist has no entries")
+	}
+
+	return playlist, nil
+}
+
+func extractPlaylistId(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if playlistIdRe.MatchString(input) && !videoIdRe.MatchString(input) {
+		return input, nil
+	}
+
+	link, err := ParseURL(input)
+	if err != nil {
+		return "", err
+	}
+	if link.PlaylistId == "" {
+		return "", &URLError{Input: input, Reason: "no playlist ID"}
+	}
+	return link.PlaylistId, nil
+}
+
+// playlistEntries collects the playlistVideoRenderers below node. offset
+// is the number of entries on earlier pages.
+func playlistEntries(node interface{}, offset int) []Entry {
+	var entries []Entry
+	walk(node, "playlistVideoRenderer", func(r map[string]interface{}) {
+		entry := Entry{
+			Index:        offset + len(entries) + 1,
+			Id:           digString(r, "videoId"),
+			Title:        textOf(r["title"]),
+			Author:       textOf(r["shortBylineText"]),
+			Availability: AvailabilityPublic,
+		}
+		if i, err := strconv.Atoi(textOf(r["index"])); err == nil {
+			entry.Index = i
+		}
+		if l, err := strconv.Atoi(digString(r, "lengthSeconds")); err == nil {
+			entry.Length_seconds = l
+		} else {
+			entry.Length_seconds = parseClock(textOf(r["lengthText"]))
+		}
+
+		if playable, ok := r["isPlayable"].(bool); ok && !playable {
+			switch entry.Title {
+			case "[Private video]":
+				entry.Availability = AvailabilityPrivate
+			case "[Deleted video]":
+				entry.Availability = AvailabilityDeleted
+			default:
+				entry.Availability = AvailabilityUnavailable
+			}
+		}
+		entries = append(entries, entry)
+	})
+	return entries
+}
+
+func playlistOwner(data map[string]interface{}) string {
+	var owner string
+	walk(data, "videoOwnerRenderer", func(r map[string]interface{}) {
+		if owner == "" {
+			owner = textOf(r["title"])
+		}
+	})
+	if owner == "" {
+		walk(data, "playlistHeaderRenderer", func(r map[string]interface{}) {
+			if owner == "" {
+				owner = textOf(r["ownerText"])
+			}
+		})
+	}
+	return owner
+}
+
+// playlistAlert returns the error message YouTube shows instead of a
+// missing or private playlist.
+func playlistAlert(data map[string]interface{}) string {
+	var msg string
+	walk(dig(data, "alerts"), "alertRenderer", func(r map[string]interface{}) {
+		if msg == "" && digString(r, "type") == "ERROR" {
+			msg = textOf(r["text"])
+		}
+	})
+	return msg
+}
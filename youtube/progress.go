@@ -0,0 +1,359 @@
+package youtube
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressKind says what a ProgressEvent reports.
+type ProgressKind int
+
+const (
+	ProgressStarted   ProgressKind = iota // the transfer begins; Downloaded is where a resumed download picks up
+	ProgressBytes                         // periodic update of Downloaded, Speed and ETA
+	ProgressRetry                         // a request failed with Err and is tried again after Wait
+	ProgressRestarted                     // the download starts over from the first byte, because of Err
+	ProgressFinished                      // the file is complete
+	ProgressFailed                        // the download stopped with Err
+)
+
+func (k ProgressKind) String() string {
+	switch k {
+	case ProgressStarted:
+		return "started"
+	case ProgressBytes:
+		return "bytes"
+	case ProgressRetry:
+		return "retry"
+	case ProgressRestarted:
+		return "restarted"
+	case ProgressFinished:
+		return "finished"
+	case ProgressFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// ProgressEvent is one report on a download. Fields that do not apply to
+// the Kind, or that are not known, are zero.
+type ProgressEvent struct {
+	Kind     ProgressKind
+	Itag     int
+	Filename string
+
+	Downloaded int64         // bytes in the file so far
+	Total      int64         // size of the stream
+	Speed      float64       // bytes per second, as a moving average
+	ETA        time.Duration // at the current speed
+	Elapsed    time.Duration // since the download started
+
+	Attempt int           // for ProgressRetry, the attempt about to start
+	Wait    time.Duration // for ProgressRetry, the backoff before it
+	Err     error
+}
+
+// Percent returns how much of the stream is downloaded, or -1 when the
+// size is unknown.
+func (e ProgressEvent) Percent() int {
+	if e.Total <= 0 {
+		return -1
+	}
+	return int(100 * e.Downloaded / e.Total)
+}
+
+// String formats the byte counts, speed and ETA for a status line, such
+// as "12.3MB/45.6MB 27% 3.2MB/s ETA 10s".
+func (e ProgressEvent) String() string {
+	s := abbr(e.Downloaded)
+	if e.Total > 0 {
+		s += fmt.Sprintf("/%s %d%%", abbr(e.Total), e.Percent())
+	}
+	if e.Speed > 0 {
+		s += fmt.Sprintf(" %s/s", abbr(int64(e.Speed)))
+	}
+	if eta := e.ETA.Round(time.Second); eta > 0 {
+		s += fmt.Sprintf(" ETA %s", eta)
+	}
+	return s
+}
+
+// ProgressFunc receives the progress of a download. Calls for one
+// download never overlap, and come from goroutines of the download, so
+// the function should return quickly.
+type ProgressFunc func(ProgressEvent)
+
+// ProgressChan returns a ProgressFunc that sends each event on ch. Byte
+// updates are dropped while ch is full; other events wait for room.
+func ProgressChan(ch chan<- ProgressEvent) ProgressFunc {
+	return func(e ProgressEvent) {
+		if e.Kind != ProgressBytes {
+			ch <- e
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// progressInterval is how often ProgressBytes is reported.
+const progressInterval = 500 * time.Millisecond
+
+// speedSmoothing weighs the latest interval in the moving average of the
+// speed.
+const speedSmoothing = 0.3
+
+// progress turns the state of a download into events for a ProgressFunc.
+// A nil fn makes every method a no-op.
+type progress struct {
+	fn       ProgressFunc
+	itag     int
+	filename string
+	total    int64
+
+	mu     sync.Mutex // serializes calls to fn and guards the fields below
+	start  time.Time
+	offset int64 // bytes there before the transfer began
+	speed  float64
+	last   int64
+	lastAt time.Time
+	ended  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newProgress(fn ProgressFunc, itag int, filename string) *progress {
+	return &progress{fn: fn, itag: itag, filename: filename, start: time.Now()}
+}
+
+func (p *progress) event(kind ProgressKind) ProgressEvent {
+	return ProgressEvent{
+		Kind:       kind,
+		Itag:       p.itag,
+		Filename:   p.filename,
+		Downloaded: p.last,
+		Total:      p.total,
+		Speed:      p.speed,
+		ETA:        p.eta(),
+		Elapsed:    time.Since(p.start),
+	}
+}
+
+func (p *progress) eta() time.Duration {
+	if p.speed <= 0 || p.total <= p.last {
+		return 0
+	}
+	return time.Duration(float64(p.total-p.last) / p.speed * float64(time.Second))
+}
+
+// begin reports the start of the transfer of total bytes, offset of which
+// are already there.
+func (p *progress) begin(offset, total int64) {
+	if p.fn == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total, p.offset, p.last, p.lastAt = total, offset, offset, time.Now()
+	p.fn(p.event(ProgressStarted))
+}
+
+// update records that downloaded bytes are now there and reports them.
+// The speed is a moving average over the updates; yt-dlp, which measures
+// its own, passes it as speed, and eta when it has one.
+func (p *progress) update(downloaded, total int64, speed float64, eta time.Duration) {
+	if p.fn == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ended {
+		return
+	}
+
+	now := time.Now()
+	if speed == 0 && now.After(p.lastAt) {
+		rate := max(float64(downloaded-p.last), 0) / now.Sub(p.lastAt).Seconds()
+		if p.speed == 0 {
+			speed = rate
+		} else {
+			speed = speedSmoothing*rate + (1-speedSmoothing)*p.speed
+		}
+	}
+	if total > 0 {
+		p.total = total
+	}
+	p.last, p.lastAt, p.speed = downloaded, now, speed
+
+	e := p.event(ProgressBytes)
+	if eta > 0 {
+		e.ETA = eta
+	}
+	p.fn(e)
+}
+
+// watch reports the count in written every progressInterval until end.
+func (p *progress) watch(written *atomic.Int64) {
+	if p.fn == nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.update(written.Load(), 0, 0, 0)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// retry reports that a request is about to be tried again.
+func (p *progress) retry(attempt int, wait time.Duration, err error) {
+	if p.fn == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.event(ProgressRetry)
+	e.Attempt, e.Wait, e.Err = attempt, wait, err
+	p.fn(e)
+}
+
+// restart reports that what was downloaded is dropped because of err.
+func (p *progress) restart(err error) {
+	if p.fn == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.offset, p.last, p.lastAt = 0, 0, time.Now()
+	e := p.event(ProgressRestarted)
+	e.Err = err
+	p.fn(e)
+}
+
+// end stops the updates and reports how the download ended. Only the
+// first call has an effect.
+func (p *progress) end(err error) {
+	if p.fn == nil {
+		return
+	}
+	if p.stop != nil {
+		close(p.stop)
+		<-p.done
+		p.stop = nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ended {
+		return
+	}
+	p.ended = true
+
+	if err != nil {
+		e := p.event(ProgressFailed)
+		e.Err = err
+		p.fn(e)
+		return
+	}
+	if p.total > 0 {
+		p.last = p.total
+	}
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		p.speed = float64(p.last-p.offset) / elapsed
+	}
+	p.fn(p.event(ProgressFinished))
+}
+
+// ytDlpProgressTemplate makes yt-dlp print its progress as lines that
+// ytDlpOutput can pick out of the rest of its output.
+const ytDlpProgressTemplate = "download:" + ytDlpProgressPrefix +
+	" %(progress.downloaded_bytes)s %(progress.total_bytes)s %(progress.total_bytes_estimate)s" +
+	" %(progress.speed)s %(progress.eta)s"
+
+const ytDlpProgressPrefix = "[ytdl-progress]"
+
+// ytDlpRetryRe matches yt-dlp's report of a failed request it retries,
+// e.g. "[download] Got error: HTTP Error 503. Retrying fragment 4 (1/10)..."
+var ytDlpRetryRe = regexp.MustCompile(`Got error: (.*?)\. Retrying.*\((\d+)/\d+\)`)
+
+// ytDlpOutput reads the output of yt-dlp a line at a time, reporting its
+// progress lines and retries to progress and passing every other line on
+// to out.
+type ytDlpOutput struct {
+	progress *progress
+	out      io.Writer
+	buf      []byte
+}
+
+func (w *ytDlpOutput) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(string(w.buf[:i+1]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// flush passes on what is left of an unterminated last line.
+func (w *ytDlpOutput) flush() {
+	if len(w.buf) > 0 {
+		w.line(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *ytDlpOutput) line(line string) {
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(line), ytDlpProgressPrefix); ok {
+		f := strings.Fields(rest)
+		if len(f) == 5 {
+			downloaded := int64(parseYtDlpNumber(f[0]))
+			total := int64(parseYtDlpNumber(f[1]))
+			if total == 0 {
+				total = int64(parseYtDlpNumber(f[2]))
+			}
+			eta := time.Duration(parseYtDlpNumber(f[4]) * float64(time.Second))
+			w.progress.update(downloaded, total, parseYtDlpNumber(f[3]), eta)
+			return
+		}
+	}
+
+	if m := ytDlpRetryRe.FindStringSubmatch(line); m != nil {
+		count, _ := strconv.Atoi(m[2])
+		w.progress.retry(count+1, 0, errors.New(m[1]))
+	}
+	io.WriteString(w.out, line)
+}
+
+// parseYtDlpNumber reads a field of the progress template, which is "NA"
+// when yt-dlp does not know the value.
+func parseYtDlpNumber(s string) float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,119 @@
+package youtube
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// partial records what an unfinished download is a copy of. It is kept
+// next to the output file as <file>.ytdl while the download runs, so that
+// a resumed download only adds bytes from the same stream, and is removed
+// once the download completes.
+type partial struct {
+	Video_id      string `json:"video_id"`
+	Itag          int    `json:"itag"`
+	Length        int64  `json:"length"`
+	Etag          string `json:"etag,omitempty"`
+	Last_modified string `json:"last_modified,omitempty"`
+
+	// Set when the download was split into ranges. The file then has holes
+	// where chunks are missing, so its size says nothing about progress.
+	Chunk_size int64 `json:"chunk_size,omitempty"`
+	Done       []int `json:"done,omitempty"` // completed chunks, by index
+
+	path string
+	mu   sync.Mutex
+}
+
+func partialPath(filename string) string {
+	return filename + ".ytdl"
+}
+
+func loadPartial(filename string) (*partial, error) {
+	data, err := os.ReadFile(partialPath(filename))
+	if err != nil {
+		return nil, err
+	}
+	p := &partial{path: partialPath(filename)}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// sameStream reports whether p and q describe the same bytes. A validator
+// the server sent both times must not have changed.
+func (p *partial) sameStream(q *partial) bool {
+	if p.Video_id != q.Video_id || p.Itag != q.Itag || p.Length != q.Length {
+		return false
+	}
+	if p.Etag != "" && q.Etag != "" && p.Etag != q.Etag {
+		return false
+	}
+	if p.Last_modified != "" && q.Last_modified != "" && p.Last_modified != q.Last_modified {
+		return false
+	}
+	return true
+}
+
+// validator returns the value for an If-Range header, preferring the
+// ETag, or "" when the server sent neither.
+func (p *partial) validator() string {
+	if p.Etag != "" {
+		return p.Etag
+	}
+	return p.Last_modified
+}
+
+func (p *partial) save() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p.path, data)
+}
+
+// chunkDone marks chunk i complete and saves the record.
+func (p *partial) chunkDone(i int) error {
+	p.mu.Lock()
+	p.Done = append(p.Done, i)
+	p.mu.Unlock()
+	return p.save()
+}
+
+// doneBytes returns how many bytes the completed chunks hold.
+func (p *partial) doneBytes() int64 {
+	var n int64
+	for _, i := range p.Done {
+		start := int64(i) * p.Chunk_size
+		n += min(start+p.Chunk_size, p.Length) - start
+	}
+	return n
+}
+
+// inFile reports whether filename still holds every completed chunk. A
+// file deleted, truncated or replaced by a shorter one since would
+// otherwise leave holes where the chunks were.
+func (p *partial) inFile(filename string) bool {
+	if p.Chunk_size == 0 {
+		return true
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false
+	}
+	for _, i := range p.Done {
+		start := int64(i) * p.Chunk_size
+		if i < 0 || start >= p.Length || min(start+p.Chunk_size, p.Length) > info.Size() {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *partial) remove() {
+	os.Remove(p.path)
+}
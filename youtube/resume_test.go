@@ -0,0 +1,118 @@
+package youtube
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResumeStaleChunks(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<14)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	const chunk = 16 << 10
+	tests := []struct {
+		name string
+		file []byte // nil for no file
+	}{
+		{"missing file", nil},
+		{"truncated file", data[:chunk]},
+		{"empty file", []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "video.mp4")
+			if tt.file != nil {
+				if err := os.WriteFile(filename, tt.file, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			state := &partial{
+				Video_id:   "x",
+				Itag:       18,
+				Length:     int64(len(data)),
+				Chunk_size: chunk,
+				Done:       []int{0, 1},
+				path:       partialPath(filename),
+			}
+			if err := state.save(); err != nil {
+				t.Fatal(err)
+			}
+
+			var restarted bool
+			video := &Video{Id: "x", Formats: []Format{{Itag: 18, Url: srv.URL}}}
+			option := &Option{
+				Resume:     true,
+				Workers:    4,
+				Chunk_size: chunk,
+				Progress: func(e ProgressEvent) {
+					if e.Kind == ProgressRestarted {
+						restarted = true
+					}
+				},
+			}
+			if err := video.Download(0, filename, option); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("file differs from the stream (%d bytes, want %d)", len(got), len(data))
+			}
+			if !restarted {
+				t.Error("no ProgressRestarted event")
+			}
+			if _, err := os.Stat(partialPath(filename)); !os.IsNotExist(err) {
+				t.Errorf("state file left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestResumeChunks(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789abcdef", 1<<14))
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			requested = append(requested, r.Header.Get("Range"))
+		}
+		http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	// The first two chunks are in the file and recorded as done
+	const chunk = 16 << 10
+	filename := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(filename, data[:2*chunk], 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := &partial{Video_id: "x", Itag: 18, Length: int64(len(data)), Chunk_size: chunk, Done: []int{0, 1}, path: partialPath(filename)}
+	if err := state.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	video := &Video{Id: "x", Formats: []Format{{Itag: 18, Url: srv.URL}}}
+	if err := video.Download(0, filename, &Option{Resume: true, Workers: 1, Chunk_size: chunk}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(filename)
+	if !bytes.Equal(got, data) {
+		t.Error("file differs from the stream")
+	}
+	for _, r := range requested {
+		if r == "bytes=0-16383" || r == "bytes=16384-32767" {
+			t.Errorf("done chunk requested again: %s", r)
+		}
+	}
+}
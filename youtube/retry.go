@@ -0,0 +1,127 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy says how the native downloader retries requests that fail
+// in a way that may go away on its own: dropped connections, truncated
+// bodies, 429 and 5xx responses. Between attempts it waits an
+// exponentially growing backoff with jitter, or as long as the server
+// asked with Retry-After.
+type RetryPolicy struct {
+	Max_attempts int           // including the first; 1 disables retrying
+	Min_backoff  time.Duration // before the first retry; doubled for each one after
+	Max_backoff  time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Max_attempts: 5,
+	Min_backoff:  time.Second,
+	Max_backoff:  30 * time.Second,
+}
+
+// statusError is an unexpected HTTP response.
+type statusError struct {
+	code       int
+	status     string
+	retryAfter time.Duration // 0 when the server did not say
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	return &statusError{
+		code:       resp.StatusCode,
+		status:     resp.Status,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *statusError) Error() string {
+	return "unexpected status " + e.status
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or
+// as an HTTP date.
+func parseRetryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// retryable reports whether a request that failed with err is worth
+// repeating.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code == http.StatusTooManyRequests || se.code >= 500
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff returns how long to wait before retry n, counting from 1: half
+// the exponential backoff plus up to as much again at random, so that
+// parallel requests do not retry in step.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.Max_backoff
+	if n < 32 && p.Min_backoff<<(n-1) < d {
+		d = p.Min_backoff << (n - 1)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// do runs fn until it succeeds, fails in a way that is not retryable, or
+// has been tried Max_attempts times. onRetry, if not nil, is told about
+// each retry before the wait.
+func (p RetryPolicy) do(ctx context.Context, onRetry func(attempt int, wait time.Duration, err error), fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		if attempt >= p.Max_attempts {
+			if attempt > 1 {
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		wait := p.backoff(attempt)
+		var se *statusError
+		if errors.As(err, &se) && se.retryAfter > 0 {
+			wait = se.retryAfter
+		}
+		if onRetry != nil {
+			onRetry(attempt+1, wait, err)
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package youtube
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+)
+
+// Search result kinds.
+const (
+	KindVideo    = "video"
+	KindChannel  = "channel"
+	KindPlaylist = "playlist"
+)
+
+type SearchSort int
+
+const (
+	SortRelevance SearchSort = iota
+	SortRating
+	SortUploadDate
+	SortViewCount
+)
+
+type UploadDate int
+
+const (
+	AnyDate UploadDate = iota
+	LastHour
+	Today
+	ThisWeek
+	ThisMonth
+	ThisYear
+)
+
+type SearchType int
+
+const (
+	AnyType SearchType = iota
+	TypeVideo
+	TypeChannel
+	TypePlaylist
+	TypeMovie
+)
+
+type SearchDuration int
+
+const (
+	AnyDuration    SearchDuration = iota
+	DurationShort                 // under 4 minutes
+	DurationLong                  // over 20 minutes
+	DurationMedium                // 4 to 20 minutes
+)
+
+// SearchFeature values are the field numbers YouTube uses for each
+// feature in the search filter message.
+type SearchFeature int
+
+const (
+	FeatureHD              SearchFeature = 4
+	FeatureSubtitles       SearchFeature = 5
+	FeatureCreativeCommons SearchFeature = 6
+	Feature3D              SearchFeature = 7
+	FeatureLive            SearchFeature = 8
+	FeaturePurchased       SearchFeature = 9
+	Feature4K              SearchFeature = 14
+	Feature360             SearchFeature = 15
+	FeatureLocation        SearchFeature = 23
+	FeatureHDR             SearchFeature = 25
+	FeatureVR180           SearchFeature = 26
+)
+
+type SearchFilters struct {
+	Upload_date UploadDate
+	Duration    SearchDuration
+	Type        SearchType
+	Sort_by     SearchSort
+	Features    []SearchFeature
+}
+
+type SearchResult struct {
+	Kind           string `json:"kind"`
+	Id             string `json:"id"`
+	Title          string `json:"title"`
+	Author         string `json:"author,omitempty"`
+	Length_seconds int    `json:"length_seconds,omitempty"`
+	Views          string `json:"views,omitempty"`       // as displayed, e.g. "1.2M views"
+	Published      string `json:"published,omitempty"`   // as displayed, e.g. "3 years ago"
+	Video_count    string `json:"video_count,omitempty"` // playlists and channels
+	Subscribers    string `json:"subscribers,omitempty"` // channels
+	Thumbnail_url  string `json:"thumbnail_url,omitempty"`
+}
+
+// SearchPage is one page of search results. Call Next to fetch the
+// following page.
+type SearchPage struct {
+	Query        string
+	Results      []SearchResult
+	continuation string
+}
+
+var ErrNoMoreResults = errors.New("no more search results")
+
+// Search runs a query with optional filters and returns the first page of
+// video, channel and playlist results.
+func Search(query string, filters *SearchFilters) (*SearchPage, error) {
+	return SearchContext(context.Background(), query, filters)
+}
+
+// SearchContext is like Search but gives up when ctx is done.
+func SearchContext(ctx context.Context, query string, filters *SearchFilters) (*SearchPage, error) {
+	body := map[string]interface{}{
+		"context": ClientWeb.context(),
+		"query":   query,
+	}
+	if params := filters.params(); params != "" {
+		body["params"] = params
+	}
+
+	var data map[string]interface{}
+	if err := innertubeRequest(ctx, "search", ClientWeb, body, &data); err != nil {
+		return nil, err
+	}
+	return newSearchPage(query, data), nil
+}
+
+// HasNext reports whether there are more results after this page.
+func (p *SearchPage) HasNext() bool {
+	return p.continuation != ""
+}
+
+// Next fetches the page following p, or returns ErrNoMoreResults.
+func (p *SearchPage) Next() (*SearchPage, error) {
+	return p.NextContext(context.Background())
+}
+
+// NextContext is like Next but gives up when ctx is done.
+func (p *SearchPage) NextContext(ctx context.Context) (*SearchPage, error) {
+	if p.continuation == "" {
+		return nil, ErrNoMoreResults
+	}
+
+	body := map[string]interface{}{
+		"context":      ClientWeb.context(),
+		"continuation": p.continuation,
+	}
+
+	var data map[string]interface{}
+	if err := innertubeRequest(ctx, "search", ClientWeb, body, &data); err != nil {
+		return nil, err
+	}
+	return newSearchPage(p.Query, data), nil
+}
+
+func newSearchPage(query string, data map[string]interface{}) *SearchPage {
+	page := &SearchPage{
+		Query:        query,
+		continuation: continuationToken(data),
+	}
+
+	keys := []string{"videoRenderer", "channelRenderer", "playlistRenderer"}
+	walkAll(data, keys, func(key string, r map[string]interface{}) {
+		var result SearchResult
+		switch key {
+		case "videoRenderer":
+			result = SearchResult{
+				Kind:           KindVideo,
+				Id:             digString(r, "videoId"),
+				Title:          textOf(r["title"]),
+				Author:         textOf(r["ownerText"]),
+				Length_seconds: parseClock(textOf(r["lengthText"])),
+				Views:          textOf(r["viewCountText"]),
+				Published:      textOf(r["publishedTimeText"]),
+				Thumbnail_url:  lastThumbnail(dig(r, "thumbnail", "thumbnails")),
+			}
+		case "channelRenderer":
+			result = SearchResult{
+				Kind:          KindChannel,
+				Id:            digString(r, "channelId"),
+				Title:         textOf(r["title"]),
+				Author:        textOf(r["title"]),
+				Video_count:   textOf(r["videoCountText"]),
+				Subscribers:   textOf(r["subscriberCountText"]),
+				Thumbnail_url: lastThumbnail(dig(r, "thumbnail", "thumbnails")),
+			}
+		case "playlistRenderer":
+			result = SearchResult{
+				Kind:          KindPlaylist,
+				Id:            digString(r, "playlistId"),
+				Title:         textOf(r["title"]),
+				Author:        textOf(r["shortBylineText"]),
+				Video_count:   digString(r, "videoCount"),
+				Thumbnail_url: lastThumbnail(dig(r, "thumbnails", 0, "thumbnails")),
+			}
+		}
+		if result.Id != "" {
+			page.Results = append(page.Results, result)
+		}
+	})
+
+	return page
+}
+
+// params encodes the filters as the base64 protobuf message YouTube
+// expects in the search "params" field:
+//
+//	1: sort order
+//	2: { 1: upload date, 2: type, 3: duration, <feature>: 1 ... }
+func (f *SearchFilters) params() string {
+	if f == nil {
+		return ""
+	}
+
+	var filter []byte
+	filter = appendVarintField(filter, 1, int(f.Upload_date))
+	filter = appendVarintField(filter, 2, int(f.Type))
+	filter = appendVarintField(filter, 3, int(f.Duration))
+	for _, feature := range f.Features {
+		filter = appendVarintField(filter, int(feature), 1)
+	}
+
+	var msg []byte
+	msg = appendVarintField(msg, 1, int(f.Sort_by))
+	if len(filter) > 0 {
+		msg = appendVarint(msg, 2<<3|2) // field 2, length-delimited
+		msg = appendVarint(msg, len(filter))
+		msg = append(msg, filter...)
+	}
+
+	if len(msg) == 0 {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(msg)
+}
+
+// appendVarintField appends a varint field, omitting zero values as
+// proto3 does.
+func appendVarintField(b []byte, field, value int) []byte {
+	if value == 0 {
+		return b
+	}
+	b = appendVarint(b, field<<3)
+	return appendVarint(b, value)
+}
+
+func appendVarint(b []byte, v int) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// ParseSearchFeature maps a feature name such as "subtitles" or "4k" to
+// its SearchFeature.
+func ParseSearchFeature(name string) (SearchFeature, bool) {
+	f, ok := searchFeatureNames[name]
+	return f, ok
+}
+
+var searchFeatureNames = map[string]SearchFeature{
+	"hd":               FeatureHD,
+	"subtitles":        FeatureSubtitles,
+	"creative-commons": FeatureCreativeCommons,
+	"3d":               Feature3D,
+	"live":             FeatureLive,
+	"purchased":        FeaturePurchased,
+	"4k":               Feature4K,
+	"360":              Feature360,
+	"location":         FeatureLocation,
+	"hdr":              FeatureHDR,
+	"vr180":            FeatureVR180,
+}
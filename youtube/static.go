@@ -0,0 +1,73 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Signature operations, named after what the player's helper methods do
+// to the signature's characters.
+const (
+	OpReverse = "reverse" // reverse the whole array
+	OpSwap    = "swap"    // swap the first element with element Arg % length
+	OpSplice  = "splice"  // drop the first Arg elements
+)
+
+// SigOp is one step of a signature transform.
+type SigOp struct {
+	Kind string `json:"op"`
+	Arg  int    `json:"arg,omitempty"`
+}
+
+// StaticDecipherer applies a fixed list of operations to signatures
+// without running any JavaScript. The operations are read from the player
+// once and cached with it. The n transform is too involved to describe
+// this way, so N always fails and streams stay throttled.
+type StaticDecipherer struct {
+	Ops []SigOp
+}
+
+var errStaticN = errors.New("static engine cannot transform n")
+
+func newStaticDecipherer(p *player) (*StaticDecipherer, error) {
+	if len(p.SigOps) == 0 {
+		if p.SigErr != "" {
+			return nil, errors.New(p.SigErr)
+		}
+		return nil, errors.New("signature transform is not a list of static operations")
+	}
+	return &StaticDecipherer{Ops: p.SigOps}, nil
+}
+
+// Signature deciphers the s parameter of a signatureCipher.
+func (d *StaticDecipherer) Signature(ctx context.Context, s string) (string, error) {
+	a := []rune(s)
+	for _, op := range d.Ops {
+		if op.Arg < 0 {
+			return "", fmt.Errorf("signature operation %q with negative argument %d", op.Kind, op.Arg)
+		}
+		switch op.Kind {
+		case OpReverse:
+			for i, j := 0, len(a)-1; i < j; i, j = i+1, j-1 {
+				a[i], a[j] = a[j], a[i]
+			}
+		case OpSwap:
+			if len(a) == 0 {
+				continue
+			}
+			i := op.Arg % len(a)
+			a[0], a[i] = a[i], a[0]
+		case OpSplice:
+			a = a[min(op.Arg, len(a)):]
+		default:
+			return "", fmt.Errorf("unknown signature operation %q", op.Kind)
+		}
+	}
+	return string(a), nil
+}
+
+// N always fails; see StaticDecipherer.
+func (d *StaticDecipherer) N(ctx context.Context, n string) (string, error) {
+	return "", errStaticN
+}
@@ -0,0 +1,192 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// FormatStatus tells whether a format's URL can be used.
+type FormatStatus int
+
+const (
+	FormatUnresolved FormatStatus = iota // not deciphered yet; see ResolveURL
+	FormatOK
+	FormatThrottled // n could not be transformed, so downloads are slow; see Err
+	FormatBroken    // the signature could not be deciphered; see Err
+)
+
+func (s FormatStatus) String() string {
+	switch s {
+	case FormatOK:
+		return "ok"
+	case FormatThrottled:
+		return "throttled"
+	case FormatBroken:
+		return "broken"
+	}
+	return "unresolved"
+}
+
+var (
+	errNoPlayerSig = errors.New("no player to decipher the signature")
+	errNoPlayerN   = errors.New("no player to transform n")
+)
+
+// ResolveURL returns the stream URL of the format, deciphering its
+// signature and n parameter on first use, and records the outcome in
+// Status and Err. Until then Url is empty for formats that need
+// deciphering. A broken format keeps failing with the same error. The URL
+// stops working at ExpiresAt.
+func (f *Format) ResolveURL(ctx context.Context) (string, error) {
+	if f.Url != "" {
+		return f.Url, nil
+	}
+	if f.Status == FormatBroken {
+		return "", f.Err
+	}
+
+	streamURL := f.rawURL
+	if f.cipher != "" {
+		deciphered, err := decipherURL(ctx, f.cipher, f.dec)
+		if err != nil {
+			if ctx.Err() == nil {
+				f.Status, f.Err = FormatBroken, err
+			}
+			return "", err
+		}
+		streamURL = deciphered
+	}
+
+	// Without the transformed n parameter the stream is throttled, but
+	// still plays
+	f.Status, f.Err = FormatOK, nil
+	if f.dec != nil {
+		transformed, err := transformNParam(ctx, streamURL, f.dec)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			f.Status = FormatUnresolved
+			return "", ctxErr
+		}
+		if err != nil {
+			f.Status, f.Err = FormatThrottled, err
+		}
+		streamURL = transformed
+	}
+
+	f.Url = streamURL
+	return streamURL, nil
+}
+
+// ResolveFormats resolves the URL of every format, so that Status and Err
+// are known for each. Only an error that is not specific to one format,
+// such as ctx ending, is returned.
+func (video *Video) ResolveFormats(ctx context.Context) error {
+	for i := range video.Formats {
+		video.Formats[i].ResolveURL(ctx)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpiresAt returns when the stream URL stops working, or the zero time
+// if YouTube did not say.
+func (f *Format) ExpiresAt() time.Time {
+	raw := f.Url
+	if raw == "" {
+		raw = f.rawURL
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return time.Time{}
+	}
+	secs, err := strconv.ParseInt(u.Query().Get("expire"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
+
+// expired reports whether the URL has expired or is about to.
+func (f *Format) expired() bool {
+	exp := f.ExpiresAt()
+	return !exp.IsZero() && time.Until(exp) < time.Minute
+}
+
+// refresh fetches the video's metadata again, the same way it was first
+// fetched, and replaces each format with its fresh counterpart. Formats
+// keep their indexes.
+func (video *Video) refresh(ctx context.Context) error {
+	fresh, err := GetWithOptionsContext(ctx, video.Id, &video.opts)
+	if err != nil {
+		return fmt.Errorf("failed to refresh metadata: %w", err)
+	}
+
+	for i := range video.Formats {
+		if _, f := fresh.IndexByItag(video.Formats[i].Itag); f != nil {
+			video.Formats[i] = *f
+		}
+	}
+	return nil
+}
+
+// streamURL resolves the URL of the format at index, refreshing the
+// metadata first if the URL held has expired.
+func (video *Video) streamURL(ctx context.Context, index int) (string, error) {
+	if video.Formats[index].expired() {
+		if err := video.refresh(ctx); err != nil {
+			return "", err
+		}
+	}
+	return video.Formats[index].ResolveURL(ctx)
+}
+
+// openStream sends a request for the format at index with the extra
+// header, which may be nil. YouTube answers 403 for stale URLs, so on 403
+// the metadata is refreshed and the request retried once with the new URL.
+func (video *Video) openStream(ctx context.Context, client *http.Client, method string, index int, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		streamURL, err := video.streamURL(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := newStreamRequest(ctx, method, streamURL)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusForbidden || attempt > 0 {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+		if err := video.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// newStreamRequest builds a request for a stream URL with the headers
+// googlevideo expects from a browser.
+func newStreamRequest(ctx context.Context, method, streamURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, streamURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	req.Header.Set("Referer", "https://www.youtube.com/")
+	return req, nil
+}
@@ -0,0 +1,24 @@
+// Runs each whole player in players/ in node and writes the outputs of
+// its transforms to the fixture JSON next to it. The functions are called
+// by the names the player was written with; nothing is extracted.
+const fs = require("fs"), path = require("path"), vm = require("vm");
+const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_";
+const sample = (len, seed) => Array.from({length: len}, (_, i) => alphabet[(i * 7 + seed) % 64]).join("");
+const players = {
+  statements: {sig: "Mt", n: "Qn", url: "https://www.youtube.com/s/player/0000a001/player_ias.vflset/en_US/base.js"},
+  sequence: {sig: "Wk", n: "Dn", url: "https://www.youtube.com/s/player/0000a002/player_ias.vflset/en_US/base.js"},
+  fallback: {sig: "Jx", n: "Gm", url: "https://www.youtube.com/s/player/0000a003/player_es6.vflset/en_US/base.js"},
+};
+for (const [name, p] of Object.entries(players)) {
+  let code = fs.readFileSync(path.join(__dirname, "players", name + ".js"), "utf8");
+  const end = code.lastIndexOf("})");
+  code = code.slice(0, end) + `;globalThis.__sig=${p.sig};globalThis.__n=${p.n};` + code.slice(end);
+  const ctx = {};
+  vm.createContext(ctx);
+  vm.runInContext(code, ctx);
+  const out = {player_url: p.url, signatures: {}, n: {}, synthetic: true};
+  for (const s of [sample(104, 0), sample(108, 7)]) out.signatures[s] = ctx.__sig(s);
+  for (const n of [sample(16, 3), sample(18, 11)]) out.n[n] = ctx.__n(n);
+  fs.writeFileSync(path.join(__dirname, "players", name + ".json"), JSON.stringify(out, null, 2) + "\n");
+  console.log("wrote", name + ".json");
+}
@@ -0,0 +1,6 @@
+(function(){var g=this;var Fj={sts:20201};
+var Tp={Ab:function(a,b){a.splice(0,b)},Cd:function(a,b){var c=a[0];a[0]=a[b%a.length];a[b%a.length]=c},Ef:function(a){a.reverse()}};
+Jx=function(a){a=a.split(""),Tp.Cd(a,62),Tp.Ef(a,9),Tp.Ab(a,2),Tp.Cd(a,21);return a.join("")};
+var Gm=function(a){var b=a.split(""),c=0;try{for(var d=b.length-1;d>0;d--){c=(c*31+b[d].charCodeAt(0)+d)%d;var e=b[d];b[d]=b[c];b[c]=e}b.push(b.shift())}catch(f){return"enhanced_except_"+f+"_"+a}return b.join("")};
+g.Hr=function(a){return Gm(a.n)};
+}).call(this);
@@ -0,0 +1,8 @@
+var _yt_player={};(function(g){var window=this;var zA="}",ZZ=/a}b/g;
+var Qc={signatureTimestamp:20158};var Zq=Qc.signatureTimestamp;
+var Hk={u2:function(a){a.reverse()},Xz:function(a,b){var c=a[0];a[0]=a[b%a.length];a[b%a.length]=c},N7:function(a,b){a.splice(0,b)}};
+Dn=function(a){var b=a.split(""),c=b.length,d=[];if(typeof Zq==="undefined")return a;try{for(var e=0;e<c;e++)d.push(b[(e*7+Zq)%c]);for(e=0;e<c;e++){var f=d[e].charCodeAt(0);d[e]=String.fromCharCode(f>=97&&f<=122?(f-97+13)%26+97:f>=65&&f<=90?(f-65+5)%26+65:f)}d.splice(0,0,d.pop())}catch(h){return"enhanced_except_"+a}return d.join("")};
+var Rz=[Dn];
+Wk=function(a){a=a.split(""),Hk.Xz(a,48);Hk["u2"](a,1);Hk.N7(a,2),Hk.Xz(a,5);return a.join("")};
+g.Ps=function(a){var b,c;(b=String.fromCharCode(110),c=a.get(b))&&(c=Rz[0](c),a.set(b,c))};
+})(_yt_player);
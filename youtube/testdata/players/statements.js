@@ -0,0 +1,10 @@
+var _yt_player={};(function(g){var window=this;
+var Ul=typeof navigator!=="undefined"?navigator.userAgent:"";var Yq={signatureTimestamp:20121,client:"WEB"};
+var QQ={Vw:function(a){a.reverse()},Bk:function(a,b){a.splice(0,b)},Rc:function(a,b){var c=a[0];a[0]=a[b%a.length];a[b%a.length]=c}};
+Qn=function(a){var b=a.split(""),c=[1937,-12,"x",b,function(d,e){d.push(e)},null,function(d){d.reverse()},function(d,e){e=(e%d.length+d.length)%d.length;d.splice(0,1,d.splice(e,1,d[0])[0])},"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"];c[5]=c;try{c[6](c[3]),c[7](c[3],c[0]),c[7](c[3],c[1]),c[6](c[3]);for(var f=0;f<b.length;f++)b[f]=c[8][(c[8].indexOf(b[f])+f*3+c[0])%64]}catch(h){return"enhanced_except_"+h+"_"+a}return b.join("")};
+var Xy=[Qn];
+function sj(a){a=a.split("");return a.join("")}
+Mt=function(a){a=a.split("");QQ.Rc(a,41);QQ.Vw(a,33);QQ.Bk(a,3);QQ.Rc(a,17);QQ.Bk(a,1);return a.join("")};
+g.Kw=function(a,b){var c;(c=a.get("n"))&&(b=Xy[0](c),a.set("n",b))};
+g.Ls=function(a,b,c){a.set(b,encodeURIComponent(Mt(decodeURIComponent(c))))};
+})(_yt_player);
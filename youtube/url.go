@@ -0,0 +1,155 @@
+package youtube
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	videoIdRe    = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	playlistIdRe = regexp.MustCompile(`^[A-Za-z0-9_-]{10,}$`)
+	timestampRe  = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s?)?$`)
+)
+
+// Hosts serving the regular watch, shorts, embed and live paths.
+var youtubeHosts = map[string]bool{
+	"youtube.com":              true,
+	"www.youtube.com":          true,
+	"m.youtube.com":            true,
+	"music.youtube.com":        true,
+	"gaming.youtube.com":       true,
+	"youtube-nocookie.com":     true,
+	"www.youtube-nocookie.com": true,
+}
+
+// System playlists whose IDs are shorter than generated ones: Watch
+// Later, Liked videos and Liked music.
+var systemPlaylists = map[string]bool{"WL": true, "LL": true, "LM": true}
+
+// Path prefixes that are followed directly by a video ID.
+var videoPathPrefixes = []string{"/shorts/", "/embed/", "/live/", "/v/", "/e/"}
+
+// Link is a normalised YouTube URL.
+type Link struct {
+	VideoId    string
+	PlaylistId string
+	Start      time.Duration
+}
+
+// URLError is returned when input is neither a video ID nor a YouTube URL
+// that can be normalised.
+type URLError struct {
+	Input  string
+	Reason string
+}
+
+func (e *URLError) Error() string {
+	return fmt.Sprintf("invalid YouTube URL %q: %s", e.Input, e.Reason)
+}
+
+// ParseURL normalises a bare video ID or any of the YouTube URL forms
+// (watch, youtu.be, shorts, embed, live, v, playlist) into a Link.
+func ParseURL(input string) (*Link, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, &URLError{Input: input, Reason: "empty input"}
+	}
+	if videoIdRe.MatchString(input) {
+		return &Link{VideoId: input}, nil
+	}
+
+	raw := input
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, &URLError{Input: input, Reason: err.Error()}
+	}
+
+	host := strings.ToLower(u.Hostname())
+	q := u.Query()
+	link := &Link{}
+
+	switch {
+	case host == "youtu.be" || host == "www.youtu.be":
+		link.VideoId = strings.Trim(u.Path, "/")
+	case youtubeHosts[host]:
+		switch {
+		case u.Path == "/watch" || u.Path == "/watch/":
+			link.VideoId = q.Get("v")
+		case u.Path == "/playlist" || u.Path == "/playlist/":
+			// Playlist-only link, VideoId stays empty.
+		default:
+			for _, prefix := range videoPathPrefixes {
+				if strings.HasPrefix(u.Path, prefix) {
+					link.VideoId = strings.SplitN(strings.TrimPrefix(u.Path, prefix), "/", 2)[0]
+					break
+				}
+			}
+			if link.VideoId == "" {
+				return nil, &URLError{Input: input, Reason: "unsupported path " + u.Path}
+			}
+		}
+	default:
+		return nil, &URLError{Input: input, Reason: "not a YouTube host"}
+	}
+
+	if link.VideoId != "" && !videoIdRe.MatchString(link.VideoId) {
+		return nil, &URLError{Input: input, Reason: fmt.Sprintf("malformed video ID %q", link.VideoId)}
+	}
+
+	// A video link stays usable whatever list it was copied from
+	if list := q.Get("list"); list != "" {
+		switch {
+		case playlistIdRe.MatchString(list) || systemPlaylists[list]:
+			link.PlaylistId = list
+		case link.VideoId == "":
+			return nil, &URLError{Input: input, Reason: fmt.Sprintf("malformed playlist ID %q", list)}
+		}
+	}
+
+	if link.VideoId == "" && link.PlaylistId == "" {
+		return nil, &URLError{Input: input, Reason: "no video or playlist ID"}
+	}
+
+	// The start offset may be given as t=1h2m3s, t=90 or start=90, in the
+	// query or in the fragment of embed links.
+	fragment, _ := url.ParseQuery(u.Fragment)
+	for _, t := range []string{q.Get("t"), q.Get("start"), fragment.Get("t")} {
+		if t == "" {
+			continue
+		}
+		if d, ok := parseTimestamp(t); ok {
+			link.Start = d
+			break
+		}
+	}
+
+	return link, nil
+}
+
+// parseTimestamp parses the t= values YouTube accepts: plain seconds,
+// "90s", or a combination such as "1h2m3s".
+func parseTimestamp(s string) (time.Duration, bool) {
+	m := timestampRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, false
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, true
+}
@@ -0,0 +1,58 @@
+package youtube
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Link
+	}{
+		{"dQw4w9WgXcQ", Link{VideoId: "dQw4w9WgXcQ"}},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", Link{VideoId: "dQw4w9WgXcQ"}},
+		{"youtube.com/watch?v=dQw4w9WgXcQ&t=1m30s", Link{VideoId: "dQw4w9WgXcQ", Start: 90 * time.Second}},
+		{"https://youtu.be/dQw4w9WgXcQ?t=42", Link{VideoId: "dQw4w9WgXcQ", Start: 42 * time.Second}},
+		{"https://m.youtube.com/shorts/dQw4w9WgXcQ", Link{VideoId: "dQw4w9WgXcQ"}},
+		{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ#t=10", Link{VideoId: "dQw4w9WgXcQ", Start: 10 * time.Second}},
+		{"https://www.youtube.com/playlist?list=PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf", Link{PlaylistId: "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf"}},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf", Link{VideoId: "dQw4w9WgXcQ", PlaylistId: "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf"}},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=RDdQw4w9WgXcQ&start_radio=1", Link{VideoId: "dQw4w9WgXcQ", PlaylistId: "RDdQw4w9WgXcQ"}},
+
+		// Links copied from Watch Later and Liked videos
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=WL&index=3", Link{VideoId: "dQw4w9WgXcQ", PlaylistId: "WL"}},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=LL", Link{VideoId: "dQw4w9WgXcQ", PlaylistId: "LL"}},
+		{"https://www.youtube.com/playlist?list=WL", Link{PlaylistId: "WL"}},
+
+		// A list that does not validate is ignored on a video link
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=x!", Link{VideoId: "dQw4w9WgXcQ"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseURL(tt.input)
+		if err != nil {
+			t.Errorf("ParseURL(%q): %v", tt.input, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("ParseURL(%q) = %+v, want %+v", tt.input, *got, tt.want)
+		}
+	}
+}
+
+func TestParseURLErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"https://vimeo.com/12345",
+		"https://www.youtube.com/watch?v=short",
+		"https://www.youtube.com/playlist?list=x!",
+		"https://www.youtube.com/feed/history",
+	} {
+		_, err := ParseURL(input)
+		var urlErr *URLError
+		if !errors.As(err, &urlErr) {
+			t.Errorf("ParseURL(%q) error = %v, want a *URLError", input, err)
+		}
+	}
+}
@@ -1,22 +1,22 @@
 package youtube
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"regexp"
-	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/dop251/goja"
 )
 
 const (
@@ -34,39 +34,109 @@ var (
 )
 
 type Video struct {
-	Id, Title, Author, Keywords, Thumbnail_url string
-	Avg_rating                                 float32
-	View_count, Length_seconds                 int
-	Formats                                    []Format
-	Filename                                   string
+	Id, Title, Author, Thumbnail_url  string
+	Description, Channel_id, Category string
+	Keywords                          []string
+	Avg_rating                        float32 // no longer populated by YouTube
+	View_count, Length_seconds        int
+	Like_count                        int // -1 when hidden or unknown
+	Publish_date, Upload_date         time.Time
+	Is_live_content                   bool
+	Is_family_safe, Is_unlisted       bool
+	Available_countries               []string
+	Formats                           []Format
+	Chapters                          []Chapter
+	Filename                          string
+
+	opts GetOptions // how the metadata was fetched, for refreshing
 }
 
 type Format struct {
 	Itag                     int
-	Video_type, Quality, Url string
+	Video_type, Quality, Url string // Url is empty until ResolveURL when it needs a signature or n transform
+
+	Mime_type, Codecs            string // Video_type split into its media type and codecs parameter
+	Quality_label, Audio_quality string
+	Width, Height, Fps           int
+	Bitrate, Average_bitrate     int
+	Content_length               int64
+	Approx_duration_ms           int64
+	Audio_sample_rate            int
+	Audio_channels               int
+	Color                        ColorInfo
+	Adaptive                     bool // video-only or audio-only stream, as opposed to muxed
+	Status                       FormatStatus
+	Err                          error // why the format is broken or throttled
+
+	rawURL string // before deciphering
+	cipher string // signatureCipher, if the URL is signed
+	dec    Decipherer
+}
+
+type ColorInfo struct {
+	Primaries, Transfer_characteristics, Matrix_coefficients string
+}
+
+// IsHDR reports whether the stream uses an HDR transfer function.
+func (c ColorInfo) IsHDR() bool {
+	return c.Transfer_characteristics == "COLOR_TRANSFER_CHARACTERISTICS_SMPTEST2084" ||
+		c.Transfer_characteristics == "COLOR_TRANSFER_CHARACTERISTICS_ARIB_STD_B67"
+}
+
+func (f *Format) HasVideo() bool {
+	return strings.HasPrefix(f.Mime_type, "video/")
+}
+
+func (f *Format) HasAudio() bool {
+	return strings.HasPrefix(f.Mime_type, "audio/") || !f.Adaptive
 }
 
 type Option struct {
 	Resume bool
 	Rename bool
 	Mp3    bool
+
+	Workers    int          // concurrent range requests; 0 or 1 downloads in a single request
+	Chunk_size int64        // bytes per range request; DefaultChunkSize when 0
+	Retry      *RetryPolicy // nil means DefaultRetryPolicy
+	Progress   ProgressFunc // receives progress events; may be nil
+
+	Split_chapters   bool   // write one file per chapter after downloading
+	Chapter_template string // see SplitChapters
 }
 
 type playerResponse struct {
-	VideoDetails struct {
+	PlayabilityStatus playabilityStatus `json:"playabilityStatus"`
+	VideoDetails      struct {
 		VideoID       string   `json:"videoId"`
 		Title         string   `json:"title"`
 		LengthSeconds string   `json:"lengthSeconds"`
 		Keywords      []string `json:"keywords"`
 		ViewCount     string   `json:"viewCount"`
 		Author        string   `json:"author"`
+		Description   string   `json:"shortDescription"`
 		Thumbnail     struct {
 			Thumbnails []struct {
 				URL string `json:"url"`
 			} `json:"thumbnails"`
 		} `json:"thumbnail"`
 		AverageRating float64 `json:"averageRating"`
+		ChannelID     string  `json:"channelId"`
+		IsLiveContent bool    `json:"isLiveContent"`
 	} `json:"videoDetails"`
+	Microformat struct {
+		PlayerMicroformatRenderer struct {
+			Description        text     `json:"description"`
+			PublishDate        string   `json:"publishDate"`
+			UploadDate         string   `json:"uploadDate"`
+			Category           string   `json:"category"`
+			IsFamilySafe       bool     `json:"isFamilySafe"`
+			IsUnlisted         bool     `json:"isUnlisted"`
+			AvailableCountries []string `json:"availableCountries"`
+			LikeCount          string   `json:"likeCount"`
+			ExternalChannelID  string   `json:"externalChannelId"`
+		} `json:"playerMicroformatRenderer"`
+	} `json:"microformat"`
 	StreamingData struct {
 		Formats         []streamFormat `json:"formats"`
 		AdaptiveFormats []streamFormat `json:"adaptiveFormats"`
@@ -74,127 +144,269 @@ type playerResponse struct {
 }
 
 type streamFormat struct {
-	Itag            int    `json:"itag"`
-	URL             string `json:"url"`
-	SignatureCipher string `json:"signatureCipher"`
-	MimeType        string `json:"mimeType"`
-	Quality         string `json:"quality"`
-	Width           int    `json:"width"`
-	Height          int    `json:"height"`
-	Bitrate         int    `json:"bitrate"`
+	Itag             int    `json:"itag"`
+	URL              string `json:"url"`
+	SignatureCipher  string `json:"signatureCipher"`
+	MimeType         string `json:"mimeType"`
+	Quality          string `json:"quality"`
+	QualityLabel     string `json:"qualityLabel"`
+	Width            int    `json:"width"`
+	Height           int    `json:"height"`
+	Fps              int    `json:"fps"`
+	Bitrate          int    `json:"bitrate"`
+	AverageBitrate   int    `json:"averageBitrate"`
+	ContentLength    string `json:"contentLength"`
+	ApproxDurationMs string `json:"approxDurationMs"`
+	AudioQuality     string `json:"audioQuality"`
+	AudioSampleRate  string `json:"audioSampleRate"`
+	AudioChannels    int    `json:"audioChannels"`
+	ColorInfo        struct {
+		Primaries               string `json:"primaries"`
+		TransferCharacteristics string `json:"transferCharacteristics"`
+		MatrixCoefficients      string `json:"matrixCoefficients"`
+	} `json:"colorInfo"`
 }
 
 func extractId(input string) (string, error) {
-	u, err := url.Parse(input)
+	link, err := ParseURL(input)
 	if err != nil {
 		return "", err
 	}
-
-	q := u.Query()
-	if v := q.Get("v"); v != "" {
-		return v, nil
+	if link.VideoId == "" {
+		return "", &URLError{Input: input, Reason: "no video ID"}
 	}
-	return "", fmt.Errorf("no video ID")
+	return link.VideoId, nil
 }
 
 func Get(video_id string) (Video, error) {
-	if strings.Contains(video_id, "youtube.com/watch?") {
-		video_id, _ = extractId(video_id)
-	}
+	return GetWithOptions(video_id, nil)
+}
 
-	query, err := fetchMeta(video_id)
-	if err != nil {
-		return Video{}, err
+// GetContext is like Get but gives up when ctx is done.
+func GetContext(ctx context.Context, video_id string) (Video, error) {
+	return GetWithOptionsContext(ctx, video_id, nil)
+}
+
+// Download writes the format at index to filename. With option.Resume, an
+// unfinished download of the same stream is continued where it stopped;
+// anything else already in the file is replaced. Requests that fail are
+// retried according to option.Retry, continuing from the last byte
+// written.
+func (video *Video) Download(index int, filename string, option *Option) error {
+	return video.DownloadContext(context.Background(), index, filename, option)
+}
+
+// DownloadContext is like Download but stops when ctx is done. What was
+// written so far is kept, ready to be continued with option.Resume.
+func (video *Video) DownloadContext(ctx context.Context, index int, filename string, option *Option) (err error) {
+	video.Filename = filename
+
+	t := &transfer{
+		video:    video,
+		index:    index,
+		client:   &http.Client{},
+		retry:    DefaultRetryPolicy,
+		progress: newProgress(option.Progress, video.Formats[index].Itag, filename),
+	}
+	if option.Retry != nil {
+		t.retry = *option.Retry
 	}
+	defer func() { t.progress.end(err) }()
 
-	meta, err := parseMeta(video_id, query)
+	// HEAD request to get content length and validators
+	var resp *http.Response
+	err = t.retry.do(ctx, t.progress.retry, func() error {
+		var err error
+		if resp, err = video.openStream(ctx, t.client, "HEAD", index, nil); err != nil {
+			return err
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return nil
+		case http.StatusForbidden:
+			return errors.New("video forbidden")
+		}
+		return newStatusError(resp)
+	})
 	if err != nil {
-		return Video{}, err
+		return err
 	}
+	size := resp.Header.Get("Content-Length")
+	if size == "" {
+		return errors.New("missing content length")
+	}
+	length, _ := strconv.ParseInt(size, 10, 64)
 
-	return *meta, nil
-}
-
-func (video *Video) Download(index int, filename string, option *Option) error {
-	var out *os.File
-	var err error
-	var offset int64
-	var length int64
+	t.state = &partial{
+		Video_id:      video.Id,
+		Itag:          video.Formats[index].Itag,
+		Length:        length,
+		Etag:          resp.Header.Get("ETag"),
+		Last_modified: resp.Header.Get("Last-Modified"),
+		path:          partialPath(filename),
+	}
 
+	resume := false
 	if option.Resume {
-		out, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE, 0644)
-		if err != nil {
-			return err
+		prev, err := loadPartial(filename)
+		switch {
+		case err == nil && prev.sameStream(t.state) && !prev.inFile(filename):
+			t.progress.restart(errors.New("partial download is missing from the file"))
+		case err == nil && prev.sameStream(t.state):
+			resume = true
+			t.state.Chunk_size, t.state.Done = prev.Chunk_size, prev.Done
+		case err == nil:
+			t.progress.restart(errors.New("partial download is of a different stream"))
+		case !os.IsNotExist(err):
+			t.progress.restart(fmt.Errorf("can't read partial download state: %w", err))
 		}
-		offset, err = out.Seek(0, os.SEEK_END)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+	if t.out, err = os.OpenFile(filename, flags, 0644); err != nil {
+		return err
+	}
+	defer t.out.Close()
+
+	var offset int64
+	if resume && t.state.Chunk_size == 0 {
+		info, err := t.out.Stat()
 		if err != nil {
 			return err
 		}
-	} else {
-		out, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-		if err != nil {
-			return err
+		offset = info.Size()
+		if offset > length {
+			if err := t.out.Truncate(0); err != nil {
+				return err
+			}
+			offset = 0
 		}
 	}
-	defer out.Close()
 
-	url := video.Formats[index].Url
-	video.Filename = filename
-
-	// Create HTTP client with proper headers
-	client := &http.Client{}
-	
-	// HEAD request to get content length
-	headReq, err := http.NewRequest("HEAD", url, nil)
-	if err != nil {
-		return err
-	}
-	headReq.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	headReq.Header.Set("Referer", "https://www.youtube.com/")
-	
-	resp, err := client.Do(headReq)
-	if err != nil {
-		return err
+	chunkSize := option.Chunk_size
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
 	}
-	resp.Body.Close()
-	
-	if resp.StatusCode == 403 {
-		return errors.New("video forbidden")
+	// Chunks need range requests, which the server advertises
+	ranges := resp.Header.Get("Accept-Ranges") == "bytes"
+	if t.state.Chunk_size == 0 && offset == 0 && option.Workers > 1 && length > chunkSize && ranges {
+		t.state.Chunk_size = chunkSize
 	}
-	size := resp.Header.Get("Content-Length")
-	if size == "" {
-		return errors.New("missing content length")
+	if err := t.state.save(); err != nil {
+		return err
 	}
-	length, _ = strconv.ParseInt(size, 10, 64)
 
-	if length > 0 {
-		go printProgress(out, offset, length)
+	if t.state.Chunk_size > 0 {
+		t.written.Store(t.state.doneBytes())
+	} else {
+		t.written.Store(offset)
 	}
+	t.progress.begin(t.written.Load(), length)
+	t.progress.watch(&t.written)
 
-	start := time.Now()
-	
-	// GET request to download video
-	getReq, err := http.NewRequest("GET", url, nil)
+	if t.state.Chunk_size > 0 {
+		err = t.downloadChunks(ctx, max(option.Workers, 1))
+		if errors.Is(err, errRangeIgnored) {
+			// Fetch the whole stream in one request instead
+			err = t.unchunk()
+		}
+	}
+	if err == nil && t.state.Chunk_size == 0 {
+		// Bytes before written are in the file, so each attempt picks up
+		// where the last one stopped
+		err = t.retry.do(ctx, t.progress.retry, func() error {
+			return t.downloadStream(ctx, t.written.Load())
+		})
+	}
 	if err != nil {
+		// The state stays behind so that the download can be resumed
 		return err
 	}
-	getReq.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	getReq.Header.Set("Referer", "https://www.youtube.com/")
-	
-	resp2, err := client.Do(getReq)
+	t.state.remove()
+	return nil
+}
+
+// transfer is what the requests of one Download share.
+type transfer struct {
+	video  *Video
+	index  int
+	client *http.Client
+	out    *os.File
+	state  *partial
+	retry  RetryPolicy
+
+	progress *progress
+
+	written atomic.Int64 // bytes of the stream in out so far
+	mu      sync.Mutex   // serializes resolving and refreshing the URL
+}
+
+// downloadStream fetches the stream from offset to the end in a single
+// GET. If the server ignores the range, or the stream changed since the
+// state was recorded, the file is rewritten from the start.
+func (t *transfer) downloadStream(ctx context.Context, offset int64) error {
+	if offset == t.state.Length {
+		return nil
+	}
+
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if v := t.state.validator(); v != "" {
+			header.Set("If-Range", v)
+		}
+	}
+	resp, err := t.video.openStream(ctx, t.client, "GET", t.index, header)
 	if err != nil {
 		return err
 	}
-	defer resp2.Body.Close()
+	defer resp.Body.Close()
 
-	if _, err = io.Copy(out, resp2.Body); err != nil {
-		return err
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if offset > 0 {
+			if err := t.restart(errRangeIgnored); err != nil {
+				return err
+			}
+			offset = 0
+		}
+	case http.StatusPartialContent:
+		start, _, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset || total != t.state.Length {
+			resp.Body.Close()
+			if err := t.restart(fmt.Errorf("server sent the wrong range: %q", resp.Header.Get("Content-Range"))); err != nil {
+				return err
+			}
+			return t.downloadStream(ctx, 0)
+		}
+	case http.StatusForbidden:
+		return errors.New("video forbidden")
+	default:
+		return newStatusError(resp)
 	}
 
-	fmt.Printf("Download took %s\n", time.Since(start))
+	n, err := io.Copy(&countingWriter{w: io.NewOffsetWriter(t.out, offset), n: &t.written}, resp.Body)
+	if err != nil {
+		return err
+	}
+	if offset+n != t.state.Length {
+		return io.ErrUnexpectedEOF
+	}
 	return nil
 }
 
+// restart empties the file for a download from the first byte, and
+// reports why.
+func (t *transfer) restart(reason error) error {
+	t.written.Store(0)
+	t.progress.restart(reason)
+	return t.out.Truncate(0)
+}
+
 // checkYtDlpInstalled checks if yt-dlp is available in PATH
 func checkYtDlpInstalled() error {
 	_, err := exec.LookPath("yt-dlp")
@@ -204,8 +416,31 @@ func checkYtDlpInstalled() error {
 	return nil
 }
 
+// ytDlpCommand runs yt-dlp until ctx is done. It is then interrupted
+// rather than killed, so that it can tidy up, and only killed if it is
+// still running after ytDlpWaitDelay.
+func ytDlpCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	cmd.Cancel = func() error {
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = ytDlpWaitDelay
+	return cmd
+}
+
+const ytDlpWaitDelay = 5 * time.Second
+
 // DownloadWithYtDlp downloads a video using yt-dlp
 func (video *Video) DownloadWithYtDlp(index int, filename string, option *Option) error {
+	return video.DownloadWithYtDlpContext(context.Background(), index, filename, option)
+}
+
+// DownloadWithYtDlpContext is like DownloadWithYtDlp but stops yt-dlp when
+// ctx is done. yt-dlp keeps its .part file, and continues it next time.
+func (video *Video) DownloadWithYtDlpContext(ctx context.Context, index int, filename string, option *Option) (err error) {
 	// Check if yt-dlp is installed
 	if err := checkYtDlpInstalled(); err != nil {
 		return err
@@ -226,31 +461,52 @@ func (video *Video) DownloadWithYtDlp(index int, filename string, option *Option
 	
 	// Add progress flag
 	args = append(args, "--progress")
+	if option.Progress != nil {
+		args = append(args, "--newline", "--progress-template", ytDlpProgressTemplate)
+	}
 	
 	fmt.Printf("Downloading → %s\n", filename)
 	fmt.Println("Using yt-dlp for download...")
 	
 	// Create command
-	cmd := exec.Command("yt-dlp", args...)
+	cmd := ytDlpCommand(ctx, args...)
 	
-	// Connect stdout and stderr to show progress
+	// Connect stdout and stderr to show progress, reading our own
+	// progress lines out of them when asked to report progress
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	p := newProgress(option.Progress, itag, filename)
+	defer func() { p.end(err) }()
+	if option.Progress != nil {
+		stdout := &ytDlpOutput{progress: p, out: os.Stdout}
+		stderr := &ytDlpOutput{progress: p, out: os.Stderr}
+		defer stdout.flush()
+		defer stderr.flush()
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+	}
 	
 	// Run the command
-	start := time.Now()
-	err := cmd.Run()
+	p.begin(0, 0)
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	if err != nil {
 		return fmt.Errorf("yt-dlp failed: %v", err)
 	}
 	
 	video.Filename = filename
-	fmt.Printf("\nDownload took %s\n", time.Since(start))
 	return nil
 }
 
 // DownloadTranscript downloads the video transcript (subtitles)
 func (video *Video) DownloadTranscript(filename, cookiesBrowser string) error {
+	return video.DownloadTranscriptContext(context.Background(), filename, cookiesBrowser)
+}
+
+// DownloadTranscriptContext is like DownloadTranscript but stops yt-dlp
+// when ctx is done.
+func (video *Video) DownloadTranscriptContext(ctx context.Context, filename, cookiesBrowser string) error {
 	// Check if yt-dlp is installed
 	if err := checkYtDlpInstalled(); err != nil {
 		return err
@@ -274,11 +530,14 @@ func (video *Video) DownloadTranscript(filename, cookiesBrowser string) error {
 	
 	fmt.Printf("Fetching transcript for %s...\n", video.Id)
 	
-	cmd := exec.Command("yt-dlp", args...)
+	cmd := ytDlpCommand(ctx, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	
 	err := cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to fetch transcript: %v", err)
 	}
@@ -311,36 +570,6 @@ func abbr(b int64) string {
 	return fmt.Sprintf("%d", b)
 }
 
-func printProgress(out *os.File, offset, length int64) {
-	var clear string
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-	start := time.Now()
-	tail := offset
-
-	for now := range ticker.C {
-		d := now.Sub(start)
-		d -= d % time.Second
-
-		cur, _ := out.Seek(0, os.SEEK_CUR)
-		speed := cur - tail
-		percent := int(100 * cur / length)
-
-		fmt.Printf("%s%s\t%s/%s\t%d%%\t%s/s\n",
-			clear, d, abbr(cur), abbr(length), percent, abbr(speed),
-		)
-
-		tail = cur
-		if tail >= length {
-			break
-		}
-
-		if clear == "" && runtime.GOOS == "darwin" {
-			clear = "\033[A\033[2K\r"
-		}
-	}
-}
-
 func (v *Video) GetExtension(index int) string {
 	for _, f := range Formats {
 		if strings.Contains(v.Formats[index].Video_type, f) {
@@ -359,9 +588,9 @@ func (v *Video) IndexByItag(itag int) (int, *Format) {
 	return 0, nil
 }
 
-func fetchMeta(video_id string) (string, error) {
+func fetchMeta(ctx context.Context, video_id string) (string, error) {
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", URL_META+video_id, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", URL_META+video_id, nil)
 	if err != nil {
 		return "", err
 	}
@@ -398,9 +627,9 @@ func extractPlayerURL(htmlContent string) (string, error) {
 }
 
 // fetchPlayerCode downloads the player JavaScript code
-func fetchPlayerCode(playerURL string) (string, error) {
+func fetchPlayerCode(ctx context.Context, playerURL string) (string, error) {
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", playerURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", playerURL, nil)
 	if err != nil {
 		return "", err
 	}
@@ -421,8 +650,32 @@ func fetchPlayerCode(playerURL string) (string, error) {
 	return string(b), nil
 }
 
-// extractDecryptFunction extracts the signature decryption function from player code
-func extractDecryptFunction(playerCode string) (string, string, error) {
+// extractSigFunction returns the name of the signature transform and the
+// code needed to run it, using the parsed player when available.
+func extractSigFunction(playerCode string, scope *jsScope) (string, string, error) {
+	if scope != nil {
+		if funcName, err := scope.findSigFunction(); err == nil {
+			if funcCode, err := scope.code(funcName); err == nil {
+				return funcName, funcCode, nil
+			}
+		}
+	}
+
+	funcName, helperName, _, err := extractDecryptFunction(playerCode)
+	if err != nil {
+		return "", "", err
+	}
+	funcCode, err := extractFullDecryptFunction(playerCode, funcName)
+	if err != nil {
+		return "", "", err
+	}
+	helperCode, _ := extractHelperObject(playerCode, helperName)
+	return funcName, helperCode + funcCode, nil
+}
+
+// extractDecryptFunction extracts the signature decryption function from player code.
+// It also returns the number of the pattern that matched, for diagnostics
+func extractDecryptFunction(playerCode string) (string, string, int, error) {
 	// Try multiple patterns to find the decryption function
 	patterns := []string{
 		// Pattern 1: Standard pattern
@@ -437,8 +690,9 @@ func extractDecryptFunction(playerCode string) (string, string, error) {
 	
 	var funcName, helperName string
 	var matches []string
+	var matched int
 	
-	for _, pattern := range patterns {
+	for i, pattern := range patterns {
 		re := regexp.MustCompile(pattern)
 		matches = re.FindStringSubmatch(playerCode)
 		if len(matches) >= 2 {
@@ -446,12 +700,13 @@ func extractDecryptFunction(playerCode string) (string, string, error) {
 			if len(matches) >= 3 {
 				helperName = matches[2]
 			}
+			matched = i + 1
 			break
 		}
 	}
 	
 	if funcName == "" {
-		return "", "", errors.New("could not find decryption function")
+		return "", "", 0, errors.New("could not find decryption function")
 	}
 	
 	// If helper name not found, try to extract it from the function body
@@ -464,7 +719,7 @@ func extractDecryptFunction(playerCode string) (string, string, error) {
 		}
 	}
 	
-	return funcName, helperName, nil
+	return funcName, helperName, matched, nil
 }
 
 // extractHelperObject extracts the helper object code
@@ -601,49 +856,8 @@ func extractFullDecryptFunction(playerCode, funcName string) (string, error) {
 	return "", fmt.Errorf("could not find complete function definition: %s", funcName)
 }
 
-// decryptSignature decrypts a signature using the player code
-func decryptSignature(signature, playerCode string) (string, error) {
-	funcName, helperName, err := extractDecryptFunction(playerCode)
-	if err != nil {
-		return "", err
-	}
-	
-	// Extract helper object if it exists
-	helperCode, _ := extractHelperObject(playerCode, helperName)
-	
-	// Extract the main function
-	funcCode, err := extractFullDecryptFunction(playerCode, funcName)
-	if err != nil {
-		return "", err
-	}
-	
-	// Create JavaScript VM
-	vm := goja.New()
-	
-	// Execute helper object and function
-	if helperCode != "" {
-		_, err = vm.RunString(helperCode)
-		if err != nil {
-			return "", fmt.Errorf("failed to execute helper code: %v", err)
-		}
-	}
-	
-	_, err = vm.RunString(funcCode)
-	if err != nil {
-		return "", fmt.Errorf("failed to execute function code: %v", err)
-	}
-	
-	// Call the decryption function
-	result, err := vm.RunString(fmt.Sprintf(`%s("%s")`, funcName, signature))
-	if err != nil {
-		return "", fmt.Errorf("failed to decrypt signature: %v", err)
-	}
-	
-	return result.String(), nil
-}
-
 // decipherURL deciphers a URL from signatureCipher
-func decipherURL(signatureCipher, playerCode string) (string, error) {
+func decipherURL(ctx context.Context, signatureCipher string, dec Decipherer) (string, error) {
 	// Parse the signature cipher
 	params, err := url.ParseQuery(signatureCipher)
 	if err != nil {
@@ -661,9 +875,12 @@ func decipherURL(signatureCipher, playerCode string) (string, error) {
 		// No signature needed, return URL as-is
 		return baseURL, nil
 	}
+	if dec == nil {
+		return "", errNoPlayerSig
+	}
 	
 	// Decrypt the signature
-	decryptedSig, err := decryptSignature(signature, playerCode)
+	decryptedSig, err := dec.Signature(ctx, signature)
 	if err != nil {
 		return "", err
 	}
@@ -680,17 +897,144 @@ func decipherURL(signatureCipher, playerCode string) (string, error) {
 	return fmt.Sprintf("%s?%s=%s", baseURL, sigParam, url.QueryEscape(decryptedSig)), nil
 }
 
-func parseMeta(video_id, htmlContent string) (*Video, error) {
+// extractNFunction finds the function that transforms the n throttling
+// parameter and returns its name and code
+func extractNFunction(playerCode string, scope *jsScope) (string, string, error) {
+	patterns := []string{
+		`\.get\("n"\)\)&&\(b=([a-zA-Z0-9$]+)(?:\[(\d+)\])?\([a-zA-Z0-9]\)`,
+		`b=String\.fromCharCode\(110\),c=a\.get\(b\)\)&&\(c=([a-zA-Z0-9$]+)(?:\[(\d+)\])?\([a-zA-Z0-9]\)`,
+		`\(c=([a-zA-Z0-9$]+)(?:\[(\d+)\])?\([a-zA-Z0-9]\),[a-zA-Z0-9$]+\.set\((?:b|"n+"),c\)`,
+	}
+
+	var funcName string
+	for _, pattern := range patterns {
+		re := regexp.MustCompile(pattern)
+		matches := re.FindStringSubmatch(playerCode)
+		if len(matches) < 2 {
+			continue
+		}
+		funcName = matches[1]
+
+		// The function may be referenced through an array, e.g. b=Xy[0](a)
+		if len(matches) >= 3 && matches[2] != "" {
+			idx, _ := strconv.Atoi(matches[2])
+			arrRe := regexp.MustCompile(fmt.Sprintf(`var %s\s*=\s*\[([^\]]+)\]`, regexp.QuoteMeta(funcName)))
+			arrMatches := arrRe.FindStringSubmatch(playerCode)
+			if len(arrMatches) < 2 {
+				return "", "", fmt.Errorf("could not find n function array: %s", funcName)
+			}
+			names := strings.Split(arrMatches[1], ",")
+			if idx >= len(names) {
+				return "", "", fmt.Errorf("n function index out of range: %s[%d]", funcName, idx)
+			}
+			funcName = strings.TrimSpace(names[idx])
+		}
+		break
+	}
+
+	// Fall back to the function that returns the "enhanced_except_" marker
+	// when the transform throws
+	if funcName == "" && scope != nil {
+		funcName = scope.findFunctionWithString("enhanced_except_")
+		if funcName == "" {
+			funcName = scope.findFunctionWithString("_w8_")
+		}
+	}
+	if funcName == "" {
+		marker := strings.Index(playerCode, `enhanced_except_`)
+		if marker < 0 {
+			marker = strings.Index(playerCode, `_w8_`)
+		}
+		if marker >= 0 {
+			from := max(0, marker-20000)
+			re := regexp.MustCompile(`([a-zA-Z0-9$]+)=function\([a-zA-Z0-9$]+\)\{`)
+			all := re.FindAllStringSubmatch(playerCode[from:marker], -1)
+			if len(all) > 0 {
+				funcName = all[len(all)-1][1]
+			}
+		}
+	}
+
+	if funcName == "" {
+		return "", "", errors.New("could not find n function")
+	}
+
+	var funcCode string
+	var err error
+	if scope != nil {
+		funcCode, err = scope.code(funcName)
+	}
+	if scope == nil || err != nil {
+		funcCode, err = extractFullDecryptFunction(playerCode, funcName)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// The function bails out early when a global it expects is undefined,
+	// which it always is outside the full player
+	typeofRe := regexp.MustCompile(`;\s*if\s*\(\s*typeof\s+[a-zA-Z0-9_$]+\s*===?\s*(?:"undefined"|'undefined'|[a-zA-Z0-9_$]+\[\d+\])\s*\)\s*return\s+[a-zA-Z0-9_$]+;`)
+	funcCode = typeofRe.ReplaceAllString(funcCode, ";")
+
+	return funcName, funcCode, nil
+}
+
+func hasNParam(streamURL string) bool {
+	u, err := url.Parse(streamURL)
+	return err == nil && u.Query().Get("n") != ""
+}
+
+// transformNParam rewrites the n parameter of a stream URL
+func transformNParam(ctx context.Context, streamURL string, dec Decipherer) (string, error) {
+	u, err := url.Parse(streamURL)
+	if err != nil {
+		return streamURL, err
+	}
+
+	q := u.Query()
+	n := q.Get("n")
+	if n == "" {
+		return streamURL, nil
+	}
+
+	transformed, err := dec.N(ctx, n)
+	if err != nil {
+		return streamURL, err
+	}
+
+	q.Set("n", transformed)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func parseMeta(ctx context.Context, video_id, htmlContent string, engine Engine) (*Video, error) {
 	// Extract ytInitialPlayerResponse from HTML
-	re := regexp.MustCompile(`var ytInitialPlayerResponse = (\{.+?\});`)
-	matches := re.FindStringSubmatch(htmlContent)
-	if len(matches) < 2 {
-		return nil, errors.New("could not find player response in page")
+	pr, err := extractPlayerResponse(htmlContent)
+	if err != nil {
+		return nil, err
 	}
 
-	var pr playerResponse
-	if err := json.Unmarshal([]byte(matches[1]), &pr); err != nil {
-		return nil, fmt.Errorf("failed to parse player response: %v", err)
+	// Extract player URL and fetch player code for signature decryption
+	var p *player
+	playerURL, err := extractPlayerURL(htmlContent)
+	if err == nil {
+		p, _ = loadPlayer(ctx, playerURL)
+	}
+
+	// ytInitialData is only needed for extras such as chapters
+	initialData, _ := extractInitialData(htmlContent)
+
+	return buildVideo(video_id, pr, initialData, p, engine)
+}
+
+// buildVideo turns a decoded player response into a Video. Formats whose
+// URL needs no signature or n transform get it right away; the others
+// decipher it with the player p on demand, using engine. p is nil when the
+// player could not be loaded, and initialData is nil when the metadata did
+// not come from a watch page.
+func buildVideo(video_id string, pr *playerResponse, initialData map[string]interface{}, p *player, engine Engine) (*Video, error) {
+	if err := pr.PlayabilityStatus.err(); err != nil {
+		return nil, err
 	}
 
 	thumbnailURL := ""
@@ -698,12 +1042,35 @@ func parseMeta(video_id, htmlContent string) (*Video, error) {
 		thumbnailURL = pr.VideoDetails.Thumbnail.Thumbnails[0].URL
 	}
 
+	mf := &pr.Microformat.PlayerMicroformatRenderer
+
 	video := &Video{
-		Id:            video_id,
-		Title:         pr.VideoDetails.Title,
-		Author:        pr.VideoDetails.Author,
-		Keywords:      fmt.Sprint(pr.VideoDetails.Keywords),
-		Thumbnail_url: thumbnailURL,
+		Id:                  video_id,
+		Title:               pr.VideoDetails.Title,
+		Author:              pr.VideoDetails.Author,
+		Keywords:            pr.VideoDetails.Keywords,
+		Thumbnail_url:       thumbnailURL,
+		Description:         pr.VideoDetails.Description,
+		Channel_id:          pr.VideoDetails.ChannelID,
+		Category:            mf.Category,
+		Is_live_content:     pr.VideoDetails.IsLiveContent,
+		Is_family_safe:      mf.IsFamilySafe,
+		Is_unlisted:         mf.IsUnlisted,
+		Available_countries: mf.AvailableCountries,
+		Publish_date:        parseDate(mf.PublishDate),
+		Upload_date:         parseDate(mf.UploadDate),
+		Like_count:          -1,
+	}
+	if video.Description == "" {
+		video.Description = mf.Description.String()
+	}
+	if video.Channel_id == "" {
+		video.Channel_id = mf.ExternalChannelID
+	}
+	if likes, err := strconv.Atoi(mf.LikeCount); err == nil {
+		video.Like_count = likes
+	} else if likes, ok := likeCountFromData(initialData); ok {
+		video.Like_count = likes
 	}
 
 	v, _ := strconv.Atoi(pr.VideoDetails.ViewCount)
@@ -712,61 +1079,55 @@ func parseMeta(video_id, htmlContent string) (*Video, error) {
 	l, _ := strconv.Atoi(pr.VideoDetails.LengthSeconds)
 	video.Length_seconds = l
 
-	// Extract player URL and fetch player code for signature decryption
-	var playerCode string
-	playerURL, err := extractPlayerURL(htmlContent)
-	if err == nil {
-		playerCode, _ = fetchPlayerCode(playerURL)
+	length := time.Duration(l) * time.Second
+	video.Chapters = chaptersFromData(initialData, length)
+	if len(video.Chapters) == 0 {
+		video.Chapters = chaptersFromDescription(pr.VideoDetails.Description, length)
 	}
 
 	// Parse formats from streamingData
-	allFormats := append(pr.StreamingData.Formats, pr.StreamingData.AdaptiveFormats...)
-	
-	for _, f := range allFormats {
-		videoURL := f.URL
-		
-		// If no direct URL, try to decipher from signatureCipher
-		if videoURL == "" && f.SignatureCipher != "" {
-			// Try to get base URL from signature cipher
-			cipherParams, err := url.ParseQuery(f.SignatureCipher)
-			if err == nil {
-				videoURL = cipherParams.Get("url")
-				
-				// If we have player code, try to decrypt signature
-				if playerCode != "" {
-					signature := cipherParams.Get("s")
-					if signature != "" {
-						decipheredURL, err := decipherURL(f.SignatureCipher, playerCode)
-						if err == nil {
-							videoURL = decipheredURL
-						}
-						// If decryption fails, we still have the base URL (download will fail with 403)
-					}
-				}
+	muxed := len(pr.StreamingData.Formats)
+	allFormats := append(pr.StreamingData.Formats[:muxed:muxed], pr.StreamingData.AdaptiveFormats...)
+
+	// One Decipherer serves every format of the video
+	var dec Decipherer
+	if p != nil {
+		dec = decipherFor(p, engine)
+	}
+
+	for i, f := range allFormats {
+		rawURL := f.URL
+		if rawURL == "" && f.SignatureCipher != "" {
+			if cipherParams, err := url.ParseQuery(f.SignatureCipher); err == nil {
+				rawURL = cipherParams.Get("url")
 			}
 		}
 		
-		if videoURL == "" {
+		if rawURL == "" {
 			// Skip formats without URL
 			continue
 		}
-		
-		// Determine quality label
-		quality := f.Quality
-		if quality == "" {
-			if f.Height > 0 {
-				quality = fmt.Sprintf("%dp", f.Height)
-			} else {
-				quality = "unknown"
-			}
+
+		// Deciphering is left to ResolveURL, so that only the formats
+		// actually used pay for it
+		format := newFormat(f, i >= muxed)
+		format.rawURL = rawURL
+		format.cipher = f.SignatureCipher
+		format.dec = dec
+		switch {
+		case format.cipher == "" && !hasNParam(rawURL):
+			// Nothing to transform, with or without a player
+			format.Url = rawURL
+			format.Status = FormatOK
+		case dec != nil:
+			// Resolved later by ResolveURL
+		case format.cipher != "":
+			format.Status, format.Err = FormatBroken, errNoPlayerSig
+		default:
+			format.Url = rawURL
+			format.Status, format.Err = FormatThrottled, errNoPlayerN
 		}
-		
-		video.Formats = append(video.Formats, Format{
-			Itag:       f.Itag,
-			Video_type: f.MimeType,
-			Quality:    quality,
-			Url:        videoURL,
-		})
+		video.Formats = append(video.Formats, format)
 	}
 
 	if len(video.Formats) == 0 {
@@ -775,3 +1136,94 @@ func parseMeta(video_id, htmlContent string) (*Video, error) {
 
 	return video, nil
 }
+
+// newFormat copies the stream metadata of f into a Format, without the URL.
+func newFormat(f streamFormat, adaptive bool) Format {
+	// Determine quality label
+	quality := f.Quality
+	if quality == "" {
+		if f.Height > 0 {
+			quality = fmt.Sprintf("%dp", f.Height)
+		} else {
+			quality = "unknown"
+		}
+	}
+
+	mimeType, codecs := parseMimeType(f.MimeType)
+	contentLength, _ := strconv.ParseInt(f.ContentLength, 10, 64)
+	duration, _ := strconv.ParseInt(f.ApproxDurationMs, 10, 64)
+	sampleRate, _ := strconv.Atoi(f.AudioSampleRate)
+
+	return Format{
+		Itag:               f.Itag,
+		Video_type:         f.MimeType,
+		Quality:            quality,
+		Mime_type:          mimeType,
+		Codecs:             codecs,
+		Quality_label:      f.QualityLabel,
+		Audio_quality:      f.AudioQuality,
+		Width:              f.Width,
+		Height:             f.Height,
+		Fps:                f.Fps,
+		Bitrate:            f.Bitrate,
+		Average_bitrate:    f.AverageBitrate,
+		Content_length:     contentLength,
+		Approx_duration_ms: duration,
+		Audio_sample_rate:  sampleRate,
+		Audio_channels:     f.AudioChannels,
+		Color: ColorInfo{
+			Primaries:                f.ColorInfo.Primaries,
+			Transfer_characteristics: f.ColorInfo.TransferCharacteristics,
+			Matrix_coefficients:      f.ColorInfo.MatrixCoefficients,
+		},
+		Adaptive: adaptive,
+	}
+}
+
+// parseMimeType splits `video/mp4; codecs="avc1.4d401f, mp4a.40.2"` into
+// "video/mp4" and "avc1.4d401f, mp4a.40.2".
+func parseMimeType(s string) (string, string) {
+	mediaType, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return strings.TrimSpace(strings.SplitN(s, ";", 2)[0]), ""
+	}
+	return mediaType, params["codecs"]
+}
+
+// parseDate parses the microformat dates, which are either plain
+// "2006-01-02" or full RFC 3339 timestamps depending on the video.
+func parseDate(s string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var likeCountRe = regexp.MustCompile(`(?i)along with ([\d,.]+) other`)
+
+// likeCountFromData reads the like count from the like button of a watch
+// page's ytInitialData.
+func likeCountFromData(data map[string]interface{}) (int, bool) {
+	if data == nil {
+		return 0, false
+	}
+
+	likes, found := 0, false
+	walk(data, "likeButtonViewModel", func(r map[string]interface{}) {
+		if found {
+			return
+		}
+		label := digString(r, "likeButtonViewModel", "toggleButtonViewModel", "toggleButtonViewModel", "defaultButtonViewModel", "buttonViewModel", "accessibilityText")
+		m := likeCountRe.FindStringSubmatch(label)
+		if m == nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.NewReplacer(",", "", ".", "").Replace(m[1]))
+		if err == nil {
+			likes, found = n, true
+		}
+	})
+	return likes, found
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 
 	youtube "example.com/ytdl/youtube"
 )
@@ -33,19 +37,90 @@ func printVideoMeta(video youtube.Video) {
 	Title	: %s
 	Author	: %s
 	Views	: %d
-	Rating	: %f`
+	Likes	: %s
+	Published: %s
+	Category: %s`
 
-	fmt.Printf(txt, video.Id, video.Title, video.Author, video.View_count, video.Avg_rating)
-	fmt.Println("\nFormats:")
+	likes := "hidden"
+	if video.Like_count >= 0 {
+		likes = strconv.Itoa(video.Like_count)
+	}
+	published := "unknown"
+	if !video.Publish_date.IsZero() {
+		published = video.Publish_date.Format("2006-01-02")
+	}
 
+	fmt.Printf(txt, video.Id, video.Title, video.Author, video.View_count, likes, published, video.Category)
+	if len(video.Chapters) > 0 {
+		fmt.Println("\nChapters:")
+		for i, c := range video.Chapters {
+			fmt.Printf("\t%d\t%s\t%s\n", i+1, c.Start, c.Title)
+		}
+	}
+
+	// Only what is known without deciphering is marked; the chosen
+	// format is checked before the download
+	fmt.Println("\nFormats:")
 	for i := 0; i < len(video.Formats); i++ {
-		fmt.Printf("\t%d\tItag %d\t%s\t%s\n",
-			i, video.Formats[i].Itag, video.Formats[i].Quality, video.Formats[i].Video_type)
+		f := &video.Formats[i]
+		note := ""
+		switch f.Status {
+		case youtube.FormatBroken:
+			note = fmt.Sprintf("\t[broken: %v]", f.Err)
+		case youtube.FormatThrottled:
+			note = "\t[throttled]"
+		}
+		fmt.Printf("\t%d\tItag %d\t%s\t%s%s\n",
+			i, f.Itag, f.Quality, f.Video_type, note)
 	}
 
 	fmt.Println()
 }
 
+// Exit codes for videos YouTube refuses to play, so scripts can tell
+// them apart without parsing the message.
+const (
+	exitPrivate        = 3
+	exitAgeRestricted  = 4
+	exitLoginRequired  = 5
+	exitMembersOnly    = 6
+	exitRegionBlocked  = 7
+	exitLiveNotStarted = 8
+	exitUnavailable    = 9
+	exitInterrupted    = 130
+)
+
+// signalContext returns a context that ends on SIGINT or SIGTERM, so that
+// downloads stop with their progress saved. A second signal kills the
+// program as usual.
+func signalContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	return ctx
+}
+
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, youtube.ErrPrivate):
+		return exitPrivate
+	case errors.Is(err, youtube.ErrAgeRestricted):
+		return exitAgeRestricted
+	case errors.Is(err, youtube.ErrLoginRequired):
+		return exitLoginRequired
+	case errors.Is(err, youtube.ErrMembersOnly):
+		return exitMembersOnly
+	case errors.Is(err, youtube.ErrRegionBlocked):
+		return exitRegionBlocked
+	case errors.Is(err, youtube.ErrLiveNotStarted):
+		return exitLiveNotStarted
+	case errors.Is(err, youtube.ErrUnavailable):
+		return exitUnavailable
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	}
+	return 1
+}
+
 func getItag(max int) int {
 	var i int
 	for {
@@ -58,30 +133,126 @@ func getItag(max int) int {
 	}
 }
 
-func downloadVideo(video youtube.Video, index int, option *youtube.Option, useYtDlp bool) error {
+// checkFormat fails fast, before anything is downloaded, when the URL of
+// the chosen format could not be deciphered. That holds with yt-dlp too:
+// the direct download it falls back to could not work either.
+func checkFormat(ctx context.Context, video youtube.Video, index int) error {
+	f := &video.Formats[index]
+	_, err := f.ResolveURL(ctx)
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case err != nil:
+		return fmt.Errorf("itag %d can't be downloaded: %w", f.Itag, err)
+	case f.Status == youtube.FormatThrottled:
+		fmt.Printf("Warning: itag %d will download slowly: %v\n", f.Itag, f.Err)
+	}
+	return nil
+}
+
+func downloadVideo(ctx context.Context, video youtube.Video, index int, option *youtube.Option, useYtDlp bool) error {
 	ext := video.GetExtension(index)
 	filename := fmt.Sprintf("%s.%s", video.Id, ext)
 
 	var err error
 	if useYtDlp {
 		// Try yt-dlp first
-		err = video.DownloadWithYtDlp(index, filename, option)
-		if err != nil {
+		err = video.DownloadWithYtDlpContext(ctx, index, filename, option)
+		if err != nil && ctx.Err() == nil {
 			fmt.Println("yt-dlp error:", err)
 			fmt.Println("Falling back to direct download...")
-			err = video.Download(index, filename, option)
+			err = video.DownloadContext(ctx, index, filename, option)
 		}
 	} else {
 		// Use direct download
-		err = video.Download(index, filename, option)
+		err = video.DownloadContext(ctx, index, filename, option)
 	}
 	
+	if ctx.Err() != nil {
+		fmt.Println("\nInterrupted; run again with -resume to continue")
+		return ctx.Err()
+	}
 	if err != nil {
 		fmt.Println("Error:", err)
-	} else {
-		fmt.Println("Downloaded:", video.Filename)
+		return err
+	}
+	fmt.Println("Downloaded:", video.Filename)
+
+	if option.Split_chapters {
+		if len(video.Chapters) == 0 {
+			fmt.Println("No chapters to split")
+			return nil
+		}
+		files, err := video.SplitChaptersContext(ctx, video.Filename, option.Chapter_template)
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Println("\nInterrupted")
+				return ctx.Err()
+			}
+			fmt.Println("Error splitting chapters:", err)
+			return err
+		}
+		fmt.Printf("Split into %d chapter files:\n", len(files))
+		for _, f := range files {
+			fmt.Println("\t" + f)
+		}
+	}
+	return nil
+}
+
+// downloadEntries downloads each playable entry of a listing in turn,
+// using the format with the given itag or, when itag is 0, the first
+// format listed. Failures are reported and skipped.
+func downloadEntries(ctx context.Context, entries []youtube.Entry, itag int, getOptions *youtube.GetOptions, option *youtube.Option, useYtDlp bool) error {
+	failed := 0
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Printf("[%d/%d] %s (%s)\n", entry.Index, len(entries), entry.Title, entry.Id)
+		if entry.Availability != youtube.AvailabilityPublic {
+			fmt.Println("Skipping:", entry.Availability)
+			continue
+		}
+
+		video, err := youtube.GetWithOptionsContext(ctx, entry.Id, getOptions)
+		if err != nil {
+			fmt.Println("Error fetching metadata:", err)
+			failed++
+			continue
+		}
+
+		index := 0
+		if itag > 0 {
+			idx, format := video.IndexByItag(itag)
+			if format == nil {
+				fmt.Println("Unknown itag:", itag)
+				failed++
+				continue
+			}
+			index = idx
+		}
+		if err := checkFormat(ctx, video, index); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Println("Error:", err)
+			failed++
+			continue
+		}
+
+		if err := downloadVideo(ctx, video, index, option, useYtDlp); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, len(entries))
 	}
-	return err
+	return nil
 }
 
 // parseVTT reads a VTT file and extracts the text content
@@ -214,7 +385,16 @@ func summarizeMeeting(meetingId int, apiUrl string) (string, error) {
 }
 
 func main() {
-	video_id := flag.String("id", "", "YouTube video ID")
+	if len(os.Args) > 1 && os.Args[1] == "search" {
+		runSearch(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "decipher-check" {
+		runDecipherCheck(os.Args[2:])
+		return
+	}
+
+	video_id := flag.String("id", "", "YouTube video ID or URL")
 	resume := flag.Bool("resume", false, "Resume download")
 	itag := flag.Int("itag", 0, "Select format by itag")
 	rename := flag.Bool("rename", false, "Rename file using title")
@@ -223,6 +403,17 @@ func main() {
 	transcript := flag.Bool("transcript", false, "Fetch transcript and get a AI Generated Summary")
 	cookiesBrowser := flag.String("cookies-browser", "", "Use cookies from browser (e.g. 'chrome', 'firefox') to bypass 429 errors")
 	apiUrl := flag.String("api-url", "https://granola-ai-app.onrender.com", "API Base URL")
+	splitChapters := flag.Bool("split-chapters", false, "Write one file per chapter after downloading (requires ffmpeg)")
+	chapterTemplate := flag.String("chapter-template", youtube.DefaultChapterTemplate, "File name template for -split-chapters; may use {id}, {index}, {title}, {ext}")
+	tab := flag.String("tab", "videos", "Channel tab to download from a channel URL: videos, shorts or streams")
+	noPlaylist := flag.Bool("no-playlist", false, "Download only the video when the URL also has a list= parameter")
+	noCache := flag.Bool("no-cache", false, "Don't read or write the on-disk player cache")
+	clearCache := flag.Bool("clear-cache", false, "Remove all cached players")
+	clients := flag.String("clients", "", "Fetch metadata through the Innertube API with these clients, in order (e.g. 'android,web'). Available: web, android, ios, tv_embedded, web_creator")
+	engine := flag.String("engine", "goja", "JavaScript engine for signature and n deciphering: goja, node, deno, qjs, static")
+	workers := flag.Int("workers", 4, "Concurrent range requests for direct downloads; 1 downloads in a single request")
+	chunkSize := flag.Int("chunk-size", 10, "Size of each range request in MiB for direct downloads")
+	retries := flag.Int("retries", youtube.DefaultRetryPolicy.Max_attempts-1, "Times a failed request is retried during direct downloads")
 	flag.Parse()
 
 	if *video_id == "" && len(os.Args) < 2 {
@@ -237,11 +428,110 @@ func main() {
 		}
 	}
 
+	if *noCache {
+		youtube.DefaultPlayerCache.Disabled = true
+	}
+	if *clearCache {
+		if err := youtube.DefaultPlayerCache.Clear(); err != nil {
+			fmt.Println("Error clearing player cache:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Player cache cleared")
+		if *video_id == "" {
+			return
+		}
+	}
+
+	getOptions := &youtube.GetOptions{}
+	var ok bool
+	if getOptions.Engine, ok = youtube.ParseEngine(*engine); !ok {
+		fmt.Println("Unknown engine:", *engine)
+		os.Exit(1)
+	}
+	if *clients != "" {
+		for _, name := range strings.Split(*clients, ",") {
+			client, ok := youtube.ClientByName(name)
+			if !ok {
+				fmt.Println("Unknown client:", name)
+				os.Exit(1)
+			}
+			getOptions.Clients = append(getOptions.Clients, client)
+		}
+	}
+
+	retry := youtube.DefaultRetryPolicy
+	retry.Max_attempts = *retries + 1
+
+	ctx := signalContext()
+
+	option := &youtube.Option{
+		Resume: *resume,
+		Rename: *rename,
+		Mp3:    *mp3,
+
+		Workers:    *workers,
+		Chunk_size: int64(*chunkSize) << 20,
+		Retry:      &retry,
+		Progress:   newProgressPrinter(os.Stdout).handle,
+
+		Split_chapters:   *splitChapters,
+		Chapter_template: *chapterTemplate,
+	}
+
+	channelTab, ok := youtube.ParseChannelTab(*tab)
+	if !ok {
+		fmt.Println("Unknown tab:", *tab)
+		os.Exit(1)
+	}
+
+	if youtube.IsChannelURL(*video_id) {
+		fmt.Println("Fetching channel...")
+		channel, err := youtube.GetChannelContext(ctx, *video_id)
+		if err != nil {
+			fmt.Println("Error fetching channel:", err)
+			os.Exit(exitCode(err))
+		}
+		entries, err := channel.EntriesContext(ctx, channelTab)
+		if err != nil {
+			fmt.Println("Error listing channel:", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("\n\tChannel\t: %s %s\n\tSubs\t: %s\n\tVideos\t: %d (%s)\n\n", channel.Name, channel.Handle, channel.Subscribers, len(entries), *tab)
+
+		if err := downloadEntries(ctx, entries, *itag, getOptions, option, *useYtDlp); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	if link, err := youtube.ParseURL(*video_id); err == nil && link.PlaylistId != "" && (link.VideoId == "" || !*noPlaylist) {
+		fmt.Println("Fetching playlist...")
+		playlist, err := youtube.GetPlaylistContext(ctx, link.PlaylistId)
+		switch {
+		case err == nil:
+			fmt.Printf("\n\tPlaylist\t: %s\n\tOwner\t\t: %s\n\tVideos\t\t: %d\n\n", playlist.Title, playlist.Owner, len(playlist.Entries))
+
+			if err := downloadEntries(ctx, playlist.Entries, *itag, getOptions, option, *useYtDlp); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitCode(err))
+			}
+			return
+		case link.VideoId == "" || ctx.Err() != nil:
+			fmt.Println("Error fetching playlist:", err)
+			os.Exit(exitCode(err))
+		default:
+			// Mixes and private lists such as Watch Later have no
+			// playlist page, but the video the link points at is fine
+			fmt.Println("Warning: can't fetch the playlist, downloading only the video:", err)
+		}
+	}
+
 	fmt.Println("Fetching metadata...")
-	video, err := youtube.Get(*video_id)
+	video, err := youtube.GetWithOptionsContext(ctx, *video_id, getOptions)
 	if err != nil {
 		fmt.Println("Error fetching metadata:", err)
-		return
+		os.Exit(exitCode(err))
 	}
 
 	printVideoMeta(video)
@@ -249,10 +539,10 @@ func main() {
 	if *transcript {
 		// Fetch transcript
 		filename := video.Id // Use ID as base filename
-		err := video.DownloadTranscript(filename, *cookiesBrowser)
+		err := video.DownloadTranscriptContext(ctx, filename, *cookiesBrowser)
 		if err != nil {
 			fmt.Println("Error fetching transcript:", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		} else {
 			// Try to find the file to upload
 			// It could be filename.en.vtt or filename.vtt
@@ -312,15 +602,13 @@ func main() {
 	} else {
 		index = getItag(len(video.Formats) - 1)
 	}
-
-	option := &youtube.Option{
-		Resume: *resume,
-		Rename: *rename,
-		Mp3:    *mp3,
+	if err := checkFormat(ctx, video, index); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitCode(err))
 	}
 
-	err = downloadVideo(video, index, option, *useYtDlp)
+	err = downloadVideo(ctx, video, index, option, *useYtDlp)
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }